@@ -9,6 +9,8 @@
 
 package main
 
+import "time"
+
 type Replacements struct {
 	Logo           string
 	Version        string
@@ -20,13 +22,39 @@ type Replacements struct {
 }
 
 const (
-	anyIPv4            = "0.0.0.0"
-	anyIPv6            = "::"
-	defaultAddress     = ""
-	defaultPort        = 27420
-	defaultSessionName = name
-	defaultEditor      = "vscode"
-	templateHeader     = `{{ .Logo }}
+	anyIPv4                 = "0.0.0.0"
+	anyIPv6                 = "::"
+	defaultAddress          = ""
+	defaultPort             = 27420
+	defaultSessionName      = name
+	defaultEditor           = "vscode"
+	defaultMaxSessions      = 16
+	defaultSessionIdleTTL   = time.Hour
+	defaultMaxBodySize      = 1 << 20
+	defaultHistorySize      = 100
+	defaultHistoryAge       = time.Hour
+	defaultQueueSize        = 64
+	defaultQueuePolicy      = "drop-oldest"
+	defaultHeartbeat        = 15 * time.Second
+	defaultWriteTimeout     = 10 * time.Second
+	defaultLogFormat        = "text"
+	defaultLogLevel         = "info"
+	defaultAccessLogMaxSize = 10
+	defaultAccessLogBackups = 3
+	defaultShutdownTimeout  = 10 * time.Second
+	defaultPauseTTL         = 5 * time.Minute
+	defaultPauseCleanup     = time.Minute
+	defaultSignKeyFile      = "sign.pem"
+	defaultSignMaxSkew      = 5 * time.Minute
+	defaultReloadInterval   = 5 * time.Second
+	defaultUISessionTTL     = 12 * time.Hour
+	defaultSignNonceCache   = 0
+	defaultSignRate         = 1000
+	defaultSymmetricKeyFile = "symmetric.key"
+	defaultURL              = "http://localhost:27420"
+	defaultPollInterval     = time.Second
+	defaultReconnect        = 2 * time.Second
+	templateHeader          = `{{ .Logo }}
 {{ .Name }} {{ .Version }}
 {{ .Url }}
 {{ .Copyright }}
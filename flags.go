@@ -13,6 +13,7 @@ import (
 	"fmt"
 
 	"github.com/xrdebug/xrdebug/internal/cli"
+	"github.com/xrdebug/xrdebug/internal/controller/sse"
 )
 
 var flags = map[string]cli.Flag{
@@ -64,18 +65,180 @@ var flags = map[string]cli.Flag{
 		Default:     "",
 		Description: "[for -s option] Path to private key (ed25519)",
 	},
+	"trusted-keys": {
+		Variable:    "TrustedKeys",
+		Type:        "string",
+		Default:     "",
+		Description: "[for -s option] Path to trusted client public keys file or directory [PEM or ssh-ed25519]",
+	},
+	"ui-token": {
+		Variable:    "UIToken",
+		Type:        "string",
+		Default:     "",
+		Description: "Access token required for the user interface, stream and pause controls",
+	},
+	"ui-session-ttl": {
+		Variable:    "UISessionTTL",
+		Type:        "duration",
+		Default:     defaultUISessionTTL,
+		Description: "[for -ui-token option] Expiration of the user interface login sessions",
+	},
+	"reload-interval": {
+		Variable:    "ReloadInterval",
+		Type:        "duration",
+		Default:     defaultReloadInterval,
+		Description: "Interval for checking the key and certificate files for changes [0 to reload only on SIGHUP]",
+	},
+	"sign-max-skew": {
+		Variable:    "SignMaxSkew",
+		Type:        "duration",
+		Default:     defaultSignMaxSkew,
+		Description: "[for -s option] Maximum clock skew of the signature timestamps",
+	},
+	"sign-nonce-cache": {
+		Variable:    "SignNonceCache",
+		Type:        "int",
+		Default:     defaultSignNonceCache,
+		Description: "[for -s option] Number of unexpired signature nonces remembered per key id, which caps each client at this many signed requests within twice the max skew [0 to size it for 1000 requests per second]",
+	},
+	"sign-legacy": {
+		Variable:    "SignLegacy",
+		Type:        "bool",
+		Default:     false,
+		Description: "[for -s option] Accept the legacy signature version 1 for migrating clients",
+	},
 	"n": {
 		Variable:    "SessionName",
 		Type:        "string",
 		Default:     defaultSessionName,
 		Description: "Session name",
 	},
+	"max-sessions": {
+		Variable:    "MaxSessions",
+		Type:        "int",
+		Default:     defaultMaxSessions,
+		Description: "Maximum number of sessions [use 0 for no limit]",
+	},
+	"session-idle-ttl": {
+		Variable:    "SessionIdleTTL",
+		Type:        "duration",
+		Default:     defaultSessionIdleTTL,
+		Description: "Idle time before removing a session [use 0 to keep them]",
+	},
 	"i": {
 		Variable:    "Editor",
 		Type:        "string",
 		Default:     defaultEditor,
 		Description: fmt.Sprintf("Editor to use %v", editors),
 	},
+	"max-body": {
+		Variable:    "MaxBodySize",
+		Type:        "int",
+		Default:     defaultMaxBodySize,
+		Description: "Maximum request body size in bytes",
+	},
+	"history-size": {
+		Variable:    "HistorySize",
+		Type:        "int",
+		Default:     defaultHistorySize,
+		Description: "Number of messages kept for replay [use 0 to disable]",
+	},
+	"history-age": {
+		Variable:    "HistoryAge",
+		Type:        "duration",
+		Default:     defaultHistoryAge,
+		Description: "Maximum age of messages kept for replay [use 0 for no limit]",
+	},
+	"queue-size": {
+		Variable:    "QueueSize",
+		Type:        "int",
+		Default:     defaultQueueSize,
+		Description: "Number of events queued for each stream client",
+	},
+	"queue-policy": {
+		Variable:    "QueuePolicy",
+		Type:        "string",
+		Default:     defaultQueuePolicy,
+		Description: fmt.Sprintf("Policy for stream clients falling behind %v", sse.Policies),
+	},
+	"heartbeat": {
+		Variable:    "Heartbeat",
+		Type:        "duration",
+		Default:     defaultHeartbeat,
+		Description: "Interval between stream pings [use 0 to disable]",
+	},
+	"write-timeout": {
+		Variable:    "WriteTimeout",
+		Type:        "duration",
+		Default:     defaultWriteTimeout,
+		Description: "Timeout for each stream write [use 0 to disable]",
+	},
+	"log-format": {
+		Variable:    "LogFormat",
+		Type:        "string",
+		Default:     defaultLogFormat,
+		Description: fmt.Sprintf("Log format %v", cli.LogFormats),
+	},
+	"log-level": {
+		Variable:    "LogLevel",
+		Type:        "string",
+		Default:     defaultLogLevel,
+		Description: "Minimum log level [debug info warn error]",
+	},
+	"access-log": {
+		Variable:    "AccessLog",
+		Type:        "string",
+		Default:     "",
+		Description: "Path to access log file [default: stderr]",
+	},
+	"access-log-max-size": {
+		Variable:    "AccessLogMaxSize",
+		Type:        "int",
+		Default:     defaultAccessLogMaxSize,
+		Description: "Size in megabytes for rotating the access log file [use 0 to disable]",
+	},
+	"access-log-backups": {
+		Variable:    "AccessLogBackups",
+		Type:        "int",
+		Default:     defaultAccessLogBackups,
+		Description: "Number of rotated access log files to keep",
+	},
+	"access-log-stream": {
+		Variable:    "AccessLogStream",
+		Type:        "bool",
+		Default:     false,
+		Description: "Include stream requests in the access log",
+	},
+	"shutdown-timeout": {
+		Variable:    "ShutdownTimeout",
+		Type:        "duration",
+		Default:     defaultShutdownTimeout,
+		Description: "Maximum time for the graceful shutdown",
+	},
+	"pause-ttl": {
+		Variable:    "PauseTTL",
+		Type:        "duration",
+		Default:     defaultPauseTTL,
+		Description: "Default expiration of the pause locks",
+	},
+	"pause-cleanup": {
+		Variable:    "PauseCleanup",
+		Type:        "duration",
+		Default:     defaultPauseCleanup,
+		Description: "Interval for removing expired pause locks",
+	},
+	"config": {
+		Variable:    "Config",
+		Type:        "string",
+		Default:     "",
+		Description: "Path to config file [JSON, YAML or TOML]",
+	},
+	"print-config": {
+		Variable:    "PrintConfig",
+		Type:        "bool",
+		Default:     false,
+		Description: "Print the effective configuration as JSON and exit",
+	},
 	"version": {
 		Variable:    "Version",
 		Type:        "bool",
@@ -83,3 +246,132 @@ var flags = map[string]cli.Flag{
 		Description: "Show version information",
 	},
 }
+
+var keygenFlags = map[string]cli.Flag{
+	"x": {
+		Variable:    "SignPrivateKey",
+		Type:        "string",
+		Default:     defaultSignKeyFile,
+		Description: "Path to write the ed25519 private key [PEM, empty to skip]",
+	},
+	"k": {
+		Variable:    "SymmetricKey",
+		Type:        "string",
+		Default:     defaultSymmetricKeyFile,
+		Description: "Path to write the symmetric key [base64, empty to skip]",
+	},
+	"force": {
+		Variable:    "Force",
+		Type:        "bool",
+		Default:     false,
+		Description: "Overwrite existing key files",
+	},
+}
+
+var sendFlags = map[string]cli.Flag{
+	"u": {
+		Variable:    "URL",
+		Type:        "string",
+		Default:     defaultURL,
+		Description: "Server URL",
+	},
+	"n": {
+		Variable:    "SessionName",
+		Type:        "string",
+		Default:     "",
+		Description: "Session name [empty for the default session]",
+	},
+	"x": {
+		Variable:    "SignPrivateKey",
+		Type:        "string",
+		Default:     "",
+		Description: "Path to private key for signing requests (ed25519)",
+	},
+	"key-id": {
+		Variable:    "KeyID",
+		Type:        "string",
+		Default:     "",
+		Description: "[for -x option] Id of the signing key trusted by the server",
+	},
+	"t": {
+		Variable:    "Topic",
+		Type:        "string",
+		Default:     "",
+		Description: "Message topic",
+	},
+	"emote": {
+		Variable:    "Emote",
+		Type:        "string",
+		Default:     "",
+		Description: "Message emote",
+	},
+	"file-path": {
+		Variable:    "FilePath",
+		Type:        "string",
+		Default:     "",
+		Description: "File path shown with the message",
+	},
+	"file-line": {
+		Variable:    "FileLine",
+		Type:        "int",
+		Default:     0,
+		Description: "File line shown with the message",
+	},
+	"id": {
+		Variable:    "ID",
+		Type:        "string",
+		Default:     "",
+		Description: "Message id [random for pauses]",
+	},
+	"pause": {
+		Variable:    "Pause",
+		Type:        "bool",
+		Default:     false,
+		Description: "Send a pause and wait until it is continued",
+	},
+	"poll": {
+		Variable:    "PollInterval",
+		Type:        "duration",
+		Default:     defaultPollInterval,
+		Description: "Interval between pause status checks",
+	},
+}
+
+var followFlags = map[string]cli.Flag{
+	"u": {
+		Variable:    "URL",
+		Type:        "string",
+		Default:     defaultURL,
+		Description: "Server URL",
+	},
+	"n": {
+		Variable:    "SessionName",
+		Type:        "string",
+		Default:     "",
+		Description: "Session name [empty for the default session]",
+	},
+	"k": {
+		Variable:    "SymmetricKey",
+		Type:        "string",
+		Default:     "",
+		Description: "Path to symmetric key for decrypting messages (AES-GCM AE)",
+	},
+	"ui-token": {
+		Variable:    "UIToken",
+		Type:        "string",
+		Default:     "",
+		Description: "Access token of the user interface",
+	},
+	"no-color": {
+		Variable:    "NoColor",
+		Type:        "bool",
+		Default:     false,
+		Description: "Disable colored output",
+	},
+	"reconnect": {
+		Variable:    "Reconnect",
+		Type:        "duration",
+		Default:     defaultReconnect,
+		Description: "Delay before reconnecting a dropped stream",
+	},
+}
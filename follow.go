@@ -0,0 +1,300 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/xrdebug/xrdebug/internal/cipher"
+	"github.com/xrdebug/xrdebug/internal/cli"
+	"github.com/xrdebug/xrdebug/internal/dump"
+	"github.com/xrdebug/xrdebug/internal/follow"
+)
+
+// errShutdown is returned when the server sends the shutdown event
+var errShutdown = errors.New("server shut down")
+
+// FollowOptions holds the options of the follow command
+type FollowOptions struct {
+	// URL is the server URL
+	URL string
+	// SessionName is the session to follow, empty for the default one
+	SessionName string
+	// SymmetricKey is the path to the symmetric key for decrypting messages
+	SymmetricKey string
+	// UIToken is the access token of the user interface
+	UIToken string
+	// NoColor disables the ANSI colors
+	NoColor bool
+	// Reconnect is the delay before reconnecting a dropped stream
+	Reconnect time.Duration
+}
+
+// follower renders a session stream and handles the pause commands
+type follower struct {
+	baseURL      string
+	symmetricKey []byte
+	uiToken      string
+	client       *http.Client
+	mu           sync.Mutex
+	printer      *follow.Printer
+	pending      []string
+}
+
+// followCommand follows the session stream, rendering the messages in the
+// terminal. Pending pauses are continued with `c` and stopped with `s`
+// read from stdin, as key presses when it's a terminal or as lines
+// (optionally followed by the pause id) otherwise.
+func followCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	var options FollowOptions
+	set := flag.NewFlagSet("follow", flag.ExitOnError)
+	if err := cli.Parse(set, followFlags, args, &options); err != nil {
+		return err
+	}
+	if options.Reconnect <= 0 {
+		return fmt.Errorf("reconnect delay must be greater than 0")
+	}
+	baseURL := strings.TrimSuffix(options.URL, "/")
+	if options.SessionName != "" {
+		baseURL += "/sessions/" + neturl.PathEscape(options.SessionName)
+	}
+	f := &follower{
+		baseURL: baseURL,
+		uiToken: options.UIToken,
+		client:  &http.Client{},
+		printer: follow.NewPrinter(stdout, !options.NoColor && os.Getenv("NO_COLOR") == ""),
+	}
+	if options.SymmetricKey != "" {
+		symmetricKey, err := cipher.LoadSymmetricKey(options.SymmetricKey)
+		if err != nil {
+			return err
+		}
+		f.symmetricKey = symmetricKey
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if stdin != nil {
+		read := f.commands
+		if file, ok := stdin.(*os.File); ok {
+			if restore, err := follow.KeyMode(file); err == nil {
+				defer restore()
+				read = f.keys
+			}
+		}
+		go read(stdin)
+	}
+	lastEventID := ""
+	for {
+		err := f.stream(ctx, &lastEventID)
+		if errors.Is(err, errShutdown) || ctx.Err() != nil {
+			return nil
+		}
+		f.status("disconnected", fmt.Sprintf("Disconnected: %v, reconnecting in %s", err, options.Reconnect))
+		select {
+		case <-time.After(options.Reconnect):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// stream reads the session stream from the last event seen until it ends.
+func (f *follower) stream(ctx context.Context, lastEventID *string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.baseURL+"/stream", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	f.authorize(req)
+	if *lastEventID != "" {
+		req.Header.Set("Last-Event-ID", *lastEventID)
+	}
+	res, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", res.Status)
+	}
+	err = follow.ReadEvents(res.Body, func(event follow.Event) error {
+		if event.ID != "" {
+			*lastEventID = event.ID
+		}
+		return f.handle(event)
+	})
+	if err == nil {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// handle renders a stream event, decrypting it when needed.
+func (f *follower) handle(event follow.Event) error {
+	data := event.Data
+	if f.symmetricKey != nil {
+		decrypted, err := cipher.Decrypt(f.symmetricKey, data)
+		if err != nil {
+			f.status("error", fmt.Sprintf("Unable to decrypt event %s: %v", event.ID, err))
+			return nil
+		}
+		data = decrypted
+	}
+	var d dump.Dump
+	if err := json.Unmarshal([]byte(data), &d); err != nil {
+		f.status("error", fmt.Sprintf("Invalid event %s: %v", event.ID, err))
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch event.Event {
+	case "pause":
+		f.pending = append(f.pending, d.ID)
+		f.printer.Dump(d)
+	case "pause-continue":
+		if f.resolve(d.ID) {
+			f.printer.Status(event.Event, "Continued "+d.ID)
+		}
+	case "pause-stop":
+		if f.resolve(d.ID) {
+			f.printer.Status(event.Event, "Stopped "+d.ID)
+		}
+	case "pause-expired":
+		if f.resolve(d.ID) {
+			f.printer.Status(event.Event, "Expired "+d.ID)
+		}
+	case "clear":
+		f.printer.Status(event.Event, "Cleared")
+	case "shutdown":
+		f.printer.Status(event.Event, "Server shut down")
+		return errShutdown
+	default:
+		f.printer.Dump(d)
+	}
+	return nil
+}
+
+// resolve removes the pause from the pending ones, reporting whether it
+// was pending. It must be called with the lock held.
+func (f *follower) resolve(id string) bool {
+	for i, pending := range f.pending {
+		if pending == id {
+			f.pending = append(f.pending[:i], f.pending[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// status renders a status line
+func (f *follower) status(action, text string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.printer.Status(action, text)
+}
+
+// commands reads the pause commands from r, one per line.
+func (f *follower) commands(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		var id string
+		if len(fields) > 1 {
+			id = fields[1]
+		}
+		f.command(fields[0], id)
+	}
+}
+
+// keys reads the pause commands from r, one per key press.
+func (f *follower) keys(r io.Reader) {
+	key := make([]byte, 1)
+	for {
+		if _, err := r.Read(key); err != nil {
+			return
+		}
+		switch key[0] {
+		case 'c', 's':
+			f.command(string(key[0]), "")
+		}
+	}
+}
+
+// command continues (`c`) or stops (`s`) the pause, or the latest pending
+// one when id is empty.
+func (f *follower) command(name, id string) {
+	var method string
+	switch name {
+	case "c":
+		method = http.MethodDelete
+	case "s":
+		method = http.MethodPatch
+	default:
+		f.status("error", "Unknown command, enter `c` to continue or `s` to stop")
+		return
+	}
+	if id == "" {
+		f.mu.Lock()
+		if len(f.pending) > 0 {
+			id = f.pending[len(f.pending)-1]
+		}
+		f.mu.Unlock()
+	}
+	if id == "" {
+		f.status("error", "No pending pause")
+		return
+	}
+	if err := f.pause(method, id); err != nil {
+		f.status("error", err.Error())
+	}
+}
+
+// pause continues (DELETE) or stops (PATCH) a pause.
+func (f *follower) pause(method, id string) error {
+	req, err := http.NewRequest(method, f.baseURL+"/pauses/"+neturl.PathEscape(id), nil)
+	if err != nil {
+		return err
+	}
+	f.authorize(req)
+	res, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= http.StatusBadRequest {
+		body, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("%s %s: %s %s", method, id, res.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// authorize sets the user interface access token, when there's one.
+func (f *follower) authorize(req *http.Request) {
+	if f.uiToken != "" {
+		req.Header.Set("Authorization", "Bearer "+f.uiToken)
+	}
+}
@@ -0,0 +1,277 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+// Package auth protects the user interface routes with an access token.
+// Browsers log in with the token to get a session cookie, while terminal
+// clients send the token as a bearer token.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"html/template"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xrdebug/xrdebug/internal/server"
+)
+
+// Cookie names of the session and of its CSRF token
+const (
+	SessionCookie = "xrdebug_session"
+	CSRFCookie    = "xrdebug_csrf"
+)
+
+// CSRFHeader is the header carrying the CSRF token on mutating requests
+const CSRFHeader = "X-CSRF-Token"
+
+// loginTemplate is the login page
+var loginTemplate = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>xrDebug login</title>
+<style>
+body { font-family: sans-serif; display: flex; justify-content: center; align-items: center; min-height: 100vh; margin: 0; }
+form { display: flex; flex-direction: column; gap: .5em; min-width: 16em; }
+p { color: #c00; margin: 0; }
+</style>
+</head>
+<body>
+<form method="post" action="/login">
+<label for="token">Access token</label>
+<input id="token" name="token" type="password" autocomplete="current-password" autofocus required>
+<input name="next" type="hidden" value="{{ .Next }}">
+{{ if .Error }}<p>{{ .Error }}</p>{{ end }}
+<button type="submit">Log in</button>
+</form>
+</body>
+</html>
+`))
+
+// Config holds the authentication settings
+type Config struct {
+	// Token is the access token required for the user interface
+	Token string
+	// SessionTTL is the expiration of the login sessions
+	SessionTTL time.Duration
+	// Secure sets the Secure attribute of the cookies, for TLS servers
+	Secure bool
+}
+
+// session is a logged in browser
+type session struct {
+	csrf    string
+	expires time.Time
+}
+
+// Auth authenticates the user interface requests
+type Auth struct {
+	token    [sha256.Size]byte
+	ttl      time.Duration
+	secure   bool
+	mu       sync.Mutex
+	sessions map[string]session
+}
+
+// New creates an Auth for the given config.
+func New(config Config) *Auth {
+	return &Auth{
+		token:    sha256.Sum256([]byte(config.Token)),
+		ttl:      config.SessionTTL,
+		secure:   config.Secure,
+		sessions: map[string]session{},
+	}
+}
+
+// validToken reports whether token is the access token, in constant time.
+func (a *Auth) validToken(token string) bool {
+	sum := sha256.Sum256([]byte(token))
+	return subtle.ConstantTimeCompare(sum[:], a.token[:]) == 1
+}
+
+// Login handles the login page (GET) and form (POST). A valid token starts
+// a session, setting the session and CSRF cookies before redirecting to
+// the `next` path.
+func (a *Auth) Login() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		next := safeNext(r.FormValue("next"))
+		if r.Method != http.MethodPost {
+			writeLogin(w, http.StatusOK, next, "")
+			return
+		}
+		if !a.validToken(r.PostFormValue("token")) {
+			server.Reject(r, "Invalid access token")
+			writeLogin(w, http.StatusUnauthorized, next, "Invalid access token")
+			return
+		}
+		id, err := randomToken()
+		if err != nil {
+			http.Error(w, "Unable to start session", http.StatusInternalServerError)
+			return
+		}
+		csrf, err := randomToken()
+		if err != nil {
+			http.Error(w, "Unable to start session", http.StatusInternalServerError)
+			return
+		}
+		now := time.Now()
+		expires := now.Add(a.ttl)
+		a.mu.Lock()
+		for key, s := range a.sessions {
+			if now.After(s.expires) {
+				delete(a.sessions, key)
+			}
+		}
+		a.sessions[id] = session{csrf: csrf, expires: expires}
+		a.mu.Unlock()
+		http.SetCookie(w, a.cookie(SessionCookie, id, expires, true))
+		http.SetCookie(w, a.cookie(CSRFCookie, csrf, expires, false))
+		http.Redirect(w, r, next, http.StatusSeeOther)
+	}
+}
+
+// Logout ends the session of the request, clearing its cookies.
+func (a *Auth) Logout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie(SessionCookie); err == nil {
+			a.mu.Lock()
+			delete(a.sessions, cookie.Value)
+			a.mu.Unlock()
+		}
+		http.SetCookie(w, a.cookie(SessionCookie, "", time.Unix(0, 0), true))
+		http.SetCookie(w, a.cookie(CSRFCookie, "", time.Unix(0, 0), false))
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+	}
+}
+
+// cookie returns a session cookie for the whole site. The CSRF cookie is
+// readable by the user interface scripts, the session one is not.
+func (a *Auth) cookie(name, value string, expires time.Time, httpOnly bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: httpOnly,
+		Secure:   a.secure,
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+// authenticate reports whether the request carries the access token as a
+// bearer token, or a session cookie with the CSRF token for the methods
+// other than GET, HEAD and OPTIONS. It returns the rejection reason.
+func (a *Auth) authenticate(r *http.Request) (bool, string) {
+	if bearer, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); found {
+		if a.validToken(bearer) {
+			return true, ""
+		}
+		return false, "Invalid access token"
+	}
+	cookie, err := r.Cookie(SessionCookie)
+	if err != nil {
+		return false, "Missing session"
+	}
+	a.mu.Lock()
+	s, found := a.sessions[cookie.Value]
+	a.mu.Unlock()
+	if !found || time.Now().After(s.expires) {
+		return false, "Invalid session"
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true, ""
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(CSRFHeader)), []byte(s.csrf)) != 1 {
+		return false, "Invalid CSRF token"
+	}
+	return true, ""
+}
+
+// Require is a middleware that rejects the unauthenticated requests with
+// 401 Unauthorized, or 403 Forbidden for a missing or invalid CSRF token.
+func (a *Auth) Require(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, reason := a.authenticate(r)
+		if ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		server.Reject(r, reason)
+		status := http.StatusUnauthorized
+		if reason == "Invalid CSRF token" {
+			status = http.StatusForbidden
+		}
+		http.Error(w, reason, status)
+	})
+}
+
+// RequirePage is a middleware that redirects the unauthenticated page
+// requests to the login page.
+func (a *Auth) RequirePage(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ok, _ := a.authenticate(r); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		http.Redirect(w, r, "/login?next="+template.URLQueryEscaper(r.URL.Path), http.StatusSeeOther)
+	})
+}
+
+// writeLogin writes the login page.
+func writeLogin(w http.ResponseWriter, status int, next, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	loginTemplate.Execute(w, struct {
+		Next  string
+		Error string
+	}{next, message})
+}
+
+// safeNext returns the path to redirect after login, which must be local.
+// Control characters are rejected as browsers strip them, which would turn
+// a path such as "/\t/host" into the "//host" network path.
+func safeNext(next string) string {
+	if strings.ContainsFunc(next, isControl) || strings.Contains(next, "\\") {
+		return "/"
+	}
+	parsed, err := url.Parse(next)
+	if err != nil || parsed.Scheme != "" || parsed.Host != "" || parsed.User != nil {
+		return "/"
+	}
+	// The decoded path is checked too, as in "/%2F/host"
+	if !strings.HasPrefix(parsed.Path, "/") || strings.HasPrefix(parsed.Path, "//") ||
+		strings.ContainsFunc(parsed.Path, isControl) || strings.HasPrefix(path.Clean(parsed.Path), "//") {
+		return "/"
+	}
+	return next
+}
+
+// isControl reports whether r is an ASCII control character.
+func isControl(r rune) bool {
+	return r < 0x20 || r == 0x7f
+}
+
+// randomToken returns 32 random bytes encoded as hex.
+func randomToken() (string, error) {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
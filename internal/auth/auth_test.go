@@ -0,0 +1,249 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// login posts the token to the login form and returns the response.
+func login(a *Auth, token, next string) *httptest.ResponseRecorder {
+	form := url.Values{"token": {token}, "next": {next}}
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	a.Login().ServeHTTP(w, req)
+	return w
+}
+
+// cookies returns the cookies set by the response by name.
+func cookies(w *httptest.ResponseRecorder) map[string]*http.Cookie {
+	result := map[string]*http.Cookie{}
+	for _, cookie := range w.Result().Cookies() {
+		result[cookie.Name] = cookie
+	}
+	return result
+}
+
+func TestLogin(t *testing.T) {
+	a := New(Config{Token: "secret", SessionTTL: time.Hour})
+	w := login(a, "wrong", "/")
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+	if len(cookies(w)) != 0 {
+		t.Error("Expected no cookies for an invalid token")
+	}
+	w = login(a, "secret", "/sessions/test")
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("Expected status %d, got %d", http.StatusSeeOther, w.Code)
+	}
+	if location := w.Header().Get("Location"); location != "/sessions/test" {
+		t.Errorf("Expected location /sessions/test, got %s", location)
+	}
+	set := cookies(w)
+	session, csrf := set[SessionCookie], set[CSRFCookie]
+	if session == nil || csrf == nil {
+		t.Fatalf("Expected session and CSRF cookies, got %v", set)
+	}
+	if !session.HttpOnly || csrf.HttpOnly {
+		t.Error("Expected only the session cookie to be HttpOnly")
+	}
+	if session.SameSite != http.SameSiteStrictMode {
+		t.Errorf("Expected SameSite strict, got %v", session.SameSite)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/login", nil)
+	w = httptest.NewRecorder()
+	a.Login().ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `name="token"`) {
+		t.Errorf("Expected the login form, got %d", w.Code)
+	}
+}
+
+func TestRequire(t *testing.T) {
+	a := New(Config{Token: "secret", SessionTTL: time.Hour})
+	set := cookies(login(a, "secret", "/"))
+	session, csrf := set[SessionCookie], set[CSRFCookie]
+	handler := a.Require(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	tests := []struct {
+		name    string
+		method  string
+		cookie  string
+		csrf    string
+		bearer  string
+		status  int
+		message string
+	}{
+		{
+			name:    "anonymous",
+			method:  http.MethodGet,
+			status:  http.StatusUnauthorized,
+			message: "Missing session",
+		},
+		{
+			name:   "session read",
+			method: http.MethodGet,
+			cookie: session.Value,
+			status: http.StatusNoContent,
+		},
+		{
+			name:    "unknown session",
+			method:  http.MethodGet,
+			cookie:  "unknown",
+			status:  http.StatusUnauthorized,
+			message: "Invalid session",
+		},
+		{
+			name:    "session write without CSRF",
+			method:  http.MethodPatch,
+			cookie:  session.Value,
+			status:  http.StatusForbidden,
+			message: "Invalid CSRF token",
+		},
+		{
+			name:    "session write with invalid CSRF",
+			method:  http.MethodDelete,
+			cookie:  session.Value,
+			csrf:    "invalid",
+			status:  http.StatusForbidden,
+			message: "Invalid CSRF token",
+		},
+		{
+			name:   "session write with CSRF",
+			method: http.MethodDelete,
+			cookie: session.Value,
+			csrf:   csrf.Value,
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "bearer",
+			method: http.MethodPatch,
+			bearer: "secret",
+			status: http.StatusNoContent,
+		},
+		{
+			name:    "invalid bearer",
+			method:  http.MethodGet,
+			cookie:  session.Value,
+			bearer:  "wrong",
+			status:  http.StatusUnauthorized,
+			message: "Invalid access token",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/pauses/1", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: SessionCookie, Value: tt.cookie})
+			}
+			if tt.csrf != "" {
+				req.Header.Set(CSRFHeader, tt.csrf)
+			}
+			if tt.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, w.Code)
+			}
+			if tt.message != "" && !strings.Contains(w.Body.String(), tt.message) {
+				t.Errorf("Expected message %q, got %q", tt.message, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSessionExpires(t *testing.T) {
+	a := New(Config{Token: "secret", SessionTTL: time.Hour})
+	session := cookies(login(a, "secret", "/"))[SessionCookie]
+	a.mu.Lock()
+	s := a.sessions[session.Value]
+	s.expires = time.Now().Add(-time.Second)
+	a.sessions[session.Value] = s
+	a.mu.Unlock()
+	req := httptest.NewRequest(http.MethodGet, "/stream", nil)
+	req.AddCookie(&http.Cookie{Name: SessionCookie, Value: session.Value})
+	if ok, reason := a.authenticate(req); ok || reason != "Invalid session" {
+		t.Errorf("Expected expired session to be rejected, got %v %q", ok, reason)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	a := New(Config{Token: "secret", SessionTTL: time.Hour})
+	session := cookies(login(a, "secret", "/"))[SessionCookie]
+	req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+	req.AddCookie(&http.Cookie{Name: SessionCookie, Value: session.Value})
+	w := httptest.NewRecorder()
+	a.Logout().ServeHTTP(w, req)
+	if w.Code != http.StatusSeeOther {
+		t.Errorf("Expected status %d, got %d", http.StatusSeeOther, w.Code)
+	}
+	if ok, _ := a.authenticate(req); ok {
+		t.Error("Expected session to end on logout")
+	}
+}
+
+func TestRequirePage(t *testing.T) {
+	a := New(Config{Token: "secret", SessionTTL: time.Hour})
+	handler := a.RequirePage(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/sessions/test", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("Expected status %d, got %d", http.StatusSeeOther, w.Code)
+	}
+	expected := "/login?next=%2Fsessions%2Ftest"
+	if location := w.Header().Get("Location"); location != expected {
+		t.Errorf("Expected location %s, got %s", expected, location)
+	}
+	session := cookies(login(a, "secret", "/"))[SessionCookie]
+	req.AddCookie(&http.Cookie{Name: SessionCookie, Value: session.Value})
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestSafeNext(t *testing.T) {
+	tests := map[string]string{
+		"":                    "/",
+		"/":                   "/",
+		"/sessions/test":      "/sessions/test",
+		"https://example.com": "/",
+		"//example.com":       "/",
+		"/\\example.com":      "/",
+		"sessions":            "/",
+		"/%09/example.com":    "/",
+		"/\t/example.com":     "/",
+		"/\n/example.com":     "/",
+		"/\x7f/example.com":   "/",
+		"/%2F/example.com":    "/",
+		"///example.com":      "/",
+		"/sessions?a=1#b":     "/sessions?a=1#b",
+		"javascript:alert(1)": "/",
+		"http:/example.com":   "/",
+	}
+	for next, expected := range tests {
+		if got := safeNext(next); got != expected {
+			t.Errorf("Expected safeNext(%q) %q, got %q", next, expected, got)
+		}
+	}
+}
@@ -11,7 +11,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
 )
 
 // LoadSymmetricKey loads a 32-byte symmetric key from the specified file path.
@@ -40,6 +42,35 @@ func LoadSymmetricKey(path string) ([]byte, error) {
 	return keyData, nil
 }
 
+// Key holds a symmetric key that can be swapped while in use, such as when
+// the key file is reloaded. A nil Key holds no key.
+type Key struct {
+	value atomic.Pointer[[]byte]
+}
+
+// NewKey creates a Key holding key.
+func NewKey(key []byte) *Key {
+	k := &Key{}
+	k.Store(key)
+	return k
+}
+
+// Load returns the held key, nil when there's none.
+func (k *Key) Load() []byte {
+	if k == nil {
+		return nil
+	}
+	if key := k.value.Load(); key != nil {
+		return *key
+	}
+	return nil
+}
+
+// Store replaces the held key.
+func (k *Key) Store(key []byte) {
+	k.value.Store(&key)
+}
+
 // LoadPrivateKey loads an Ed25519 private key from the specified PEM file path.
 // If path is empty, it generates a new Ed25519 key pair and returns the private key.
 // It returns the private key and any error encountered.
@@ -86,6 +117,55 @@ func PemKey(privateKey ed25519.PrivateKey) (string, error) {
 	return strings.TrimSpace(string(pemKey)), nil
 }
 
+// WriteKeyFile writes the key data to path with permissions restricted to
+// the owner. It fails if the file exists, unless overwrite is true.
+func WriteKeyFile(path string, data []byte, overwrite bool) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if overwrite {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	file, err := os.OpenFile(path, flags, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create key file: %w", err)
+	}
+	if err := file.Chmod(0o600); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to set key file permissions: %w", err)
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+	return file.Close()
+}
+
+// CheckKeyFile checks that WriteKeyFile can write path, without modifying
+// it. It fails if the file exists, unless overwrite is true, or if the file
+// or its directory is not writable.
+func CheckKeyFile(path string, overwrite bool) error {
+	info, err := os.Stat(path)
+	switch {
+	case err == nil && !overwrite:
+		return fmt.Errorf("failed to create key file: %w", &os.PathError{Op: "open", Path: path, Err: os.ErrExist})
+	case err == nil && info.IsDir():
+		return fmt.Errorf("failed to create key file: %s is a directory", path)
+	case err == nil:
+		file, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return fmt.Errorf("failed to create key file: %w", err)
+		}
+		return file.Close()
+	case !os.IsNotExist(err):
+		return fmt.Errorf("failed to create key file: %w", err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), ".xrdebug-key-*")
+	if err != nil {
+		return fmt.Errorf("failed to create key file: %w", err)
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
 func Base64(key []byte) string {
 	return base64.StdEncoding.EncodeToString(key)
 }
@@ -109,3 +189,29 @@ func Encrypt(symmetricKey []byte, msg string) string {
 	ciphertext := gcm.Seal(nonce, nonce, []byte(msg), nil)
 	return base64.StdEncoding.EncodeToString(ciphertext)
 }
+
+// Decrypt reverses Encrypt, taking the base64-encoded ciphertext prefixed
+// with the nonce and returning the original message.
+func Decrypt(symmetricKey []byte, encrypted string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("invalid ciphertext encoding: %w", err)
+	}
+	block, err := aes.NewCipher(symmetricKey)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("ciphertext too short")
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,98 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package cipher
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDecrypt(t *testing.T) {
+	key, err := LoadSymmetricKey("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := `{"action":"message","message":"<b>hello</b>"}`
+	encrypted := Encrypt(key, msg)
+	if encrypted == msg {
+		t.Fatal("Expected message to be encrypted")
+	}
+	got, err := Decrypt(key, encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("Expected %q, got %q", msg, got)
+	}
+	otherKey := bytes.Repeat([]byte{1}, 32)
+	if _, err := Decrypt(otherKey, encrypted); err == nil {
+		t.Error("Expected error decrypting with another key")
+	}
+	for _, invalid := range []string{"not base64!", "AAAA"} {
+		if _, err := Decrypt(key, invalid); err == nil {
+			t.Errorf("Expected error for %q", invalid)
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	var empty *Key
+	if empty.Load() != nil {
+		t.Error("Expected no key for a nil Key")
+	}
+	key := NewKey([]byte("first"))
+	key.Store([]byte("second"))
+	if got := string(key.Load()); got != "second" {
+		t.Errorf("Expected second, got %s", got)
+	}
+}
+
+func TestCheckKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.pem")
+	if err := os.WriteFile(existing, []byte("key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		path      string
+		overwrite bool
+		valid     bool
+	}{
+		{"new file", filepath.Join(dir, "new.pem"), false, true},
+		{"existing file", existing, false, false},
+		{"existing file overwrite", existing, true, true},
+		{"directory", dir, true, false},
+		{"missing directory", filepath.Join(dir, "missing", "new.pem"), false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckKeyFile(tt.path, tt.overwrite)
+			if tt.valid && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the existing file, got %d entries", len(entries))
+	}
+	if data, _ := os.ReadFile(existing); string(data) != "key" {
+		t.Errorf("Expected the existing file unchanged, got %q", data)
+	}
+}
@@ -0,0 +1,222 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package cipher
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// sshKeyType is the OpenSSH key type of the ed25519 public keys
+const sshKeyType = "ssh-ed25519"
+
+// keyIDRegex matches the accepted key ids
+var keyIDRegex = regexp.MustCompile(`^[A-Za-z0-9_.@-]{1,64}$`)
+
+// trustedKeyExtensions lists the files loaded from a trusted keys directory
+var trustedKeyExtensions = map[string]bool{".pem": true, ".pub": true}
+
+// PemPublicKey converts an Ed25519 public key to PEM-encoded PKIX format.
+func PemPublicKey(publicKey ed25519.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	pemKey := pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: der,
+	})
+	return strings.TrimSpace(string(pemKey)), nil
+}
+
+// LoadTrustedKeys loads the trusted ed25519 public keys by key id from a
+// file or from the `.pem` and `.pub` files of a directory. Keys are named
+// after their file without extension, OpenSSH keys are named after their
+// comment when present.
+func LoadTrustedKeys(path string) (map[string]ed25519.PublicKey, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trusted keys: %w", err)
+	}
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read trusted keys: %w", err)
+		}
+		files = nil
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || strings.HasPrefix(name, ".") || !trustedKeyExtensions[filepath.Ext(name)] {
+				continue
+			}
+			files = append(files, filepath.Join(path, name))
+		}
+	}
+	keys := map[string]ed25519.PublicKey{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read trusted keys: %w", err)
+		}
+		base := filepath.Base(file)
+		parsed, err := ParsePublicKeys(data, strings.TrimSuffix(base, filepath.Ext(base)))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		for id, key := range parsed {
+			if _, found := keys[id]; found {
+				return nil, fmt.Errorf("%s: duplicate key id %s", file, id)
+			}
+			keys[id] = key
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no trusted keys found in %s", path)
+	}
+	return keys, nil
+}
+
+// ParsePublicKeys parses the ed25519 public keys in data, either PEM
+// encoded (PKIX) or OpenSSH `ssh-ed25519` lines. PEM keys are identified
+// by name, OpenSSH keys by their comment or by name when missing.
+func ParsePublicKeys(data []byte, name string) (map[string]ed25519.PublicKey, error) {
+	keys := map[string]ed25519.PublicKey{}
+	add := func(id string, key ed25519.PublicKey) error {
+		if !keyIDRegex.MatchString(id) {
+			return fmt.Errorf("invalid key id %q", id)
+		}
+		if _, found := keys[id]; found {
+			return fmt.Errorf("duplicate key id %s", id)
+		}
+		keys[id] = key
+		return nil
+	}
+	if bytes.Contains(data, []byte("-----BEGIN")) {
+		for {
+			var block *pem.Block
+			block, data = pem.Decode(data)
+			if block == nil {
+				break
+			}
+			key, err := parsePemPublicKey(block)
+			if err != nil {
+				return nil, err
+			}
+			if err := add(name, key); err != nil {
+				return nil, err
+			}
+		}
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("failed to decode PEM block")
+		}
+		return keys, nil
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, key, err := parseSSHPublicKey(line)
+		if err != nil {
+			return nil, err
+		}
+		if id == "" {
+			id = name
+		}
+		if err := add(id, key); err != nil {
+			return nil, err
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no public keys found")
+	}
+	return keys, nil
+}
+
+// KeyIDs returns the key ids sorted.
+func KeyIDs(keys map[string]ed25519.PublicKey) []string {
+	ids := make([]string, 0, len(keys))
+	for id := range keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// parsePemPublicKey parses a PKIX public key block.
+func parsePemPublicKey(block *pem.Block) (ed25519.PublicKey, error) {
+	if block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("unexpected PEM block %s, expected PUBLIC KEY", block.Type)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("key is not an ed25519 public key")
+	}
+	return publicKey, nil
+}
+
+// parseSSHPublicKey parses an OpenSSH public key line, optionally
+// prefixed by authorized_keys options, returning its comment and key.
+func parseSSHPublicKey(line string) (string, ed25519.PublicKey, error) {
+	fields := strings.Fields(line)
+	index := -1
+	for i, field := range fields {
+		if field == sshKeyType {
+			index = i
+			break
+		}
+	}
+	if index < 0 || index+1 >= len(fields) {
+		return "", nil, fmt.Errorf("unsupported public key, expected %s", sshKeyType)
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[index+1])
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid %s key encoding: %w", sshKeyType, err)
+	}
+	keyType, rest, ok := readSSHString(blob)
+	if !ok || string(keyType) != sshKeyType {
+		return "", nil, fmt.Errorf("invalid %s key", sshKeyType)
+	}
+	key, rest, ok := readSSHString(rest)
+	if !ok || len(key) != ed25519.PublicKeySize || len(rest) != 0 {
+		return "", nil, fmt.Errorf("invalid %s key", sshKeyType)
+	}
+	comment := strings.Join(fields[index+2:], " ")
+	return comment, ed25519.PublicKey(key), nil
+}
+
+// readSSHString reads a length-prefixed string of the SSH wire format.
+func readSSHString(data []byte) ([]byte, []byte, bool) {
+	if len(data) < 4 {
+		return nil, nil, false
+	}
+	length := binary.BigEndian.Uint32(data)
+	if uint32(len(data)-4) < length {
+		return nil, nil, false
+	}
+	return data[4 : 4+length], data[4+length:], true
+}
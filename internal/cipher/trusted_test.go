@@ -0,0 +1,168 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package cipher
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sshPublicKey returns the OpenSSH authorized key line of publicKey.
+func sshPublicKey(publicKey ed25519.PublicKey, comment string) string {
+	var blob []byte
+	for _, field := range [][]byte{[]byte(sshKeyType), publicKey} {
+		blob = binary.BigEndian.AppendUint32(blob, uint32(len(field)))
+		blob = append(blob, field...)
+	}
+	return strings.TrimSpace(sshKeyType + " " + base64.StdEncoding.EncodeToString(blob) + " " + comment)
+}
+
+func generatePublicKey(t *testing.T) ed25519.PublicKey {
+	t.Helper()
+	publicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return publicKey
+}
+
+func TestParsePublicKeys(t *testing.T) {
+	laptop := generatePublicKey(t)
+	ci := generatePublicKey(t)
+	pemKey, err := PemPublicKey(laptop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privatePem, err := PemKey(ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		data     string
+		expected map[string]ed25519.PublicKey
+		err      string
+	}{
+		{
+			name:     "pem",
+			data:     pemKey,
+			expected: map[string]ed25519.PublicKey{"file": laptop},
+		},
+		{
+			name:     "ssh without comment",
+			data:     sshPublicKey(laptop, ""),
+			expected: map[string]ed25519.PublicKey{"file": laptop},
+		},
+		{
+			name: "ssh lines",
+			data: "# team keys\n" + sshPublicKey(laptop, "rodolfo@laptop") + "\n\n" +
+				`restrict,command="true" ` + sshPublicKey(ci, "ci"),
+			expected: map[string]ed25519.PublicKey{"rodolfo@laptop": laptop, "ci": ci},
+		},
+		{
+			name: "duplicate id",
+			data: sshPublicKey(laptop, "ci") + "\n" + sshPublicKey(ci, "ci"),
+			err:  "duplicate key id ci",
+		},
+		{
+			name: "invalid id",
+			data: sshPublicKey(laptop, "my laptop"),
+			err:  "invalid key id",
+		},
+		{
+			name: "unsupported type",
+			data: "ssh-rsa AAAAB3NzaC1yc2E user",
+			err:  "unsupported public key",
+		},
+		{
+			name: "invalid ssh key",
+			data: "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5 user",
+			err:  "invalid ssh-ed25519 key",
+		},
+		{
+			name: "private key",
+			data: privatePem,
+			err:  "unexpected PEM block PRIVATE KEY",
+		},
+		{
+			name: "empty",
+			data: "# nothing\n",
+			err:  "no public keys found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := ParsePublicKeys([]byte(tt.data), "file")
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(keys, tt.expected) {
+				t.Errorf("Expected keys %v, got %v", KeyIDs(tt.expected), KeyIDs(keys))
+			}
+		})
+	}
+}
+
+func TestLoadTrustedKeys(t *testing.T) {
+	laptop := generatePublicKey(t)
+	ci := generatePublicKey(t)
+	pemKey, err := PemPublicKey(laptop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"laptop.pem":  pemKey,
+		"ci.pub":      sshPublicKey(ci, ""),
+		"README.md":   "not a key",
+		".hidden.pub": "ignored",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	keys, err := LoadTrustedKeys(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]ed25519.PublicKey{"laptop": laptop, "ci": ci}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected keys %v, got %v", KeyIDs(expected), KeyIDs(keys))
+	}
+	keys, err = LoadTrustedKeys(filepath.Join(dir, "laptop.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(keys, map[string]ed25519.PublicKey{"laptop": laptop}) {
+		t.Errorf("Expected the laptop key, got %v", KeyIDs(keys))
+	}
+	if err := os.WriteFile(filepath.Join(dir, "other.pub"), []byte(sshPublicKey(ci, "ci")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTrustedKeys(dir); err == nil || !strings.Contains(err.Error(), "duplicate key id ci") {
+		t.Errorf("Expected duplicate key id error, got %v", err)
+	}
+	if _, err := LoadTrustedKeys(t.TempDir()); err == nil {
+		t.Error("Expected error for a directory without keys")
+	}
+}
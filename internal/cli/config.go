@@ -0,0 +1,337 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// integerRegex matches the integer values in YAML and TOML files
+var integerRegex = regexp.MustCompile(`^[-+]?[0-9]+$`)
+
+// configParsers maps the config file extensions to their parsers
+var configParsers = map[string]func([]byte) (map[string]any, error){
+	".json": parseJSONConfig,
+	".yaml": parseYAMLConfig,
+	".yml":  parseYAMLConfig,
+	".toml": parseTOMLConfig,
+}
+
+// configExcluded lists the Options fields that can't be set from a config file
+var configExcluded = map[string]bool{
+	"Config":      true,
+	"PrintConfig": true,
+	"Version":     true,
+}
+
+// configSecret lists the Options fields left out of WriteConfig, as they
+// hold secrets
+var configSecret = map[string]bool{
+	"UIToken": true,
+}
+
+// ConfigKey returns the config file key for a flag variable, which is the
+// variable in snake case (`TLSCert` is `tls_cert`).
+func ConfigKey(variable string) string {
+	runes := []rune(variable)
+	var key strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prevLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				key.WriteByte('_')
+			}
+		}
+		key.WriteRune(unicode.ToLower(r))
+	}
+	return key.String()
+}
+
+// LoadConfig reads a flat config file of scalar values, its format (JSON,
+// YAML or TOML) is selected by the file extension.
+func LoadConfig(path string) (map[string]any, error) {
+	parser, found := configParsers[strings.ToLower(filepath.Ext(path))]
+	if !found {
+		return nil, fmt.Errorf("config file '%s' must be .json, .yaml, .yml or .toml", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	values, err := parser(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file '%s': %w", path, err)
+	}
+	return values, nil
+}
+
+// applyConfig sets the Options fields from the config values, skipping the
+// flags in explicit. It returns every invalid or unknown key found.
+func applyConfig(fields reflect.Value, flags map[string]Flag, values map[string]any, explicit map[string]bool) []error {
+	var errs []error
+	known := make(map[string]bool)
+	for _, name := range sortedNames(flags) {
+		item := flags[name]
+		if configExcluded[item.Variable] {
+			continue
+		}
+		key := ConfigKey(item.Variable)
+		known[key] = true
+		value, found := values[key]
+		if !found || explicit[name] {
+			continue
+		}
+		converted, err := convertValue(value, item.Type)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Invalid value for `%s` in config file: %v", key, err))
+			continue
+		}
+		fields.FieldByName(item.Variable).Set(reflect.ValueOf(converted))
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !known[key] {
+			errs = append(errs, fmt.Errorf("Unknown key `%s` in config file", key))
+		}
+	}
+	return errs
+}
+
+// sortedNames returns the flag names in order
+func sortedNames(flags map[string]Flag) []string {
+	names := make([]string, 0, len(flags))
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// convertValue converts a config value to the given flag type.
+func convertValue(value any, flagType string) (any, error) {
+	switch flagType {
+	case "string":
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("must be a string")
+	case "bool":
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("must be a boolean")
+	case "int":
+		switch n := value.(type) {
+		case int64:
+			return int(n), nil
+		case json.Number:
+			if i, err := n.Int64(); err == nil {
+				return int(i), nil
+			}
+		}
+		return nil, fmt.Errorf("must be an integer")
+	case "duration":
+		if s, ok := value.(string); ok {
+			if d, err := time.ParseDuration(s); err == nil {
+				return d, nil
+			}
+		}
+		return nil, fmt.Errorf("must be a duration string such as \"10s\"")
+	}
+	return nil, fmt.Errorf("unsupported type %s", flagType)
+}
+
+// WriteConfig writes the options as a JSON config file, which can be read
+// back with `-config`. Secrets are left out.
+func WriteConfig(w io.Writer, flags map[string]Flag, options Options) error {
+	fields := reflect.ValueOf(options)
+	config := make(map[string]any)
+	for _, item := range flags {
+		if configExcluded[item.Variable] || configSecret[item.Variable] {
+			continue
+		}
+		field := fields.FieldByName(item.Variable)
+		if !field.IsValid() {
+			continue
+		}
+		value := field.Interface()
+		if d, ok := value.(time.Duration); ok {
+			value = d.String()
+		}
+		config[ConfigKey(item.Variable)] = value
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// parseJSONConfig parses a JSON object of scalar values.
+func parseJSONConfig(data []byte) (map[string]any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var values map[string]any
+	if err := decoder.Decode(&values); err != nil {
+		return nil, err
+	}
+	for key, value := range values {
+		switch value.(type) {
+		case string, bool, json.Number:
+		default:
+			return nil, fmt.Errorf("value for `%s` must be a scalar", key)
+		}
+	}
+	return values, nil
+}
+
+// parseYAMLConfig parses a flat YAML mapping of `key: value` lines.
+func parseYAMLConfig(data []byte) (map[string]any, error) {
+	return parseLines(data, ":", func(raw string) (any, error) {
+		if raw == "" || strings.HasPrefix(raw, "[") || strings.HasPrefix(raw, "{") ||
+			strings.HasPrefix(raw, "|") || strings.HasPrefix(raw, ">") {
+			return nil, fmt.Errorf("nested values are not supported")
+		}
+		switch {
+		case strings.HasPrefix(raw, `"`):
+			return unquote(raw, '"')
+		case strings.HasPrefix(raw, "'"):
+			s, err := unquote(raw, '\'')
+			if err != nil {
+				return nil, err
+			}
+			return strings.ReplaceAll(s.(string), "''", "'"), nil
+		}
+		raw = stripComment(raw)
+		switch {
+		case raw == "true":
+			return true, nil
+		case raw == "false":
+			return false, nil
+		case integerRegex.MatchString(raw):
+			return strconv.ParseInt(raw, 10, 64)
+		}
+		return raw, nil
+	})
+}
+
+// parseTOMLConfig parses a TOML document of top-level `key = value` pairs.
+func parseTOMLConfig(data []byte) (map[string]any, error) {
+	return parseLines(data, "=", func(raw string) (any, error) {
+		switch {
+		case strings.HasPrefix(raw, `"`):
+			return unquote(raw, '"')
+		case strings.HasPrefix(raw, "'"):
+			return unquote(raw, '\'')
+		}
+		raw = stripComment(raw)
+		switch {
+		case raw == "true":
+			return true, nil
+		case raw == "false":
+			return false, nil
+		case integerRegex.MatchString(raw):
+			return strconv.ParseInt(raw, 10, 64)
+		}
+		return nil, fmt.Errorf("unsupported value %s", raw)
+	})
+}
+
+// parseLines parses the `key<separator>value` lines of a flat config file,
+// skipping blank lines and comments.
+func parseLines(data []byte, separator string, parseValue func(string) (any, error)) (map[string]any, error) {
+	values := make(map[string]any)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for number := 1; scanner.Scan(); number++ {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' || strings.HasPrefix(trimmed, "-") || strings.HasPrefix(trimmed, "[") {
+			return nil, fmt.Errorf("line %d: nested values are not supported", number)
+		}
+		key, raw, found := strings.Cut(trimmed, separator)
+		if !found {
+			return nil, fmt.Errorf("line %d: expected key%svalue", number, separator)
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"'`)
+		value, err := parseValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", number, err)
+		}
+		if _, exists := values[key]; exists {
+			return nil, fmt.Errorf("line %d: duplicate key `%s`", number, key)
+		}
+		values[key] = value
+	}
+	return values, scanner.Err()
+}
+
+// unquote returns the quoted string at the start of raw, which may only be
+// followed by a comment.
+func unquote(raw string, quote byte) (any, error) {
+	end := -1
+	for i := 1; i < len(raw); i++ {
+		if quote == '"' && raw[i] == '\\' {
+			i++
+			continue
+		}
+		if raw[i] == quote {
+			if quote == '\'' && i+1 < len(raw) && raw[i+1] == '\'' {
+				i++
+				continue
+			}
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return nil, fmt.Errorf("unterminated string")
+	}
+	if rest := strings.TrimSpace(raw[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+		return nil, fmt.Errorf("unexpected %s after string", rest)
+	}
+	if quote == '\'' {
+		return raw[1:end], nil
+	}
+	s, err := strconv.Unquote(raw[:end+1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid string %s", raw[:end+1])
+	}
+	return s, nil
+}
+
+// stripComment removes a trailing ` #` comment from an unquoted value.
+func stripComment(raw string) string {
+	if i := strings.Index(raw, " #"); i >= 0 {
+		raw = raw[:i]
+	}
+	return strings.TrimSpace(raw)
+}
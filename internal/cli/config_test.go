@@ -0,0 +1,208 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFlags() map[string]Flag {
+	return map[string]Flag{
+		"a":            {Variable: "Address", Type: "string", Default: "localhost"},
+		"p":            {Variable: "Port", Type: "int", Default: 8080},
+		"c":            {Variable: "TLSCert", Type: "string", Default: ""},
+		"e":            {Variable: "EnableEncryption", Type: "bool", Default: false},
+		"heartbeat":    {Variable: "Heartbeat", Type: "duration", Default: 15 * time.Second},
+		"config":       {Variable: "Config", Type: "string", Default: ""},
+		"print-config": {Variable: "PrintConfig", Type: "bool", Default: false},
+		"ui-token":     {Variable: "UIToken", Type: "string", Default: ""},
+	}
+}
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigKey(t *testing.T) {
+	tests := map[string]string{
+		"Address":                "address",
+		"TLSCert":                "tls_cert",
+		"TLSPrivateKey":          "tls_private_key",
+		"EnableSignVerification": "enable_sign_verification",
+		"AccessLogMaxSize":       "access_log_max_size",
+	}
+	for variable, expected := range tests {
+		if got := ConfigKey(variable); got != expected {
+			t.Errorf("ConfigKey(%q) = %q, want %q", variable, got, expected)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	expected := map[string]any{
+		"address":           "127.0.0.1",
+		"port":              int64(9000),
+		"tls_cert":          "/path/to/#cert.pem",
+		"enable_encryption": true,
+		"heartbeat":         "5s",
+	}
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "yaml",
+			file: "xrdebug.yaml",
+			content: `# xrDebug
+address: 127.0.0.1
+port: 9000 # comment
+tls_cert: "/path/to/#cert.pem"
+enable_encryption: true
+heartbeat: 5s
+`,
+		},
+		{
+			name: "toml",
+			file: "xrdebug.toml",
+			content: `# xrDebug
+address = "127.0.0.1"
+port = 9000 # comment
+tls_cert = '/path/to/#cert.pem'
+enable_encryption = true
+heartbeat = "5s"
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadConfig(writeConfig(t, tt.file, tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("LoadConfig() = %v, want %v", got, expected)
+			}
+		})
+	}
+	t.Run("json", func(t *testing.T) {
+		got, err := LoadConfig(writeConfig(t, "xrdebug.json", `{"port": 9000, "enable_encryption": true}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got["port"] != json.Number("9000") || got["enable_encryption"] != true {
+			t.Errorf("LoadConfig() = %v", got)
+		}
+	})
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		error   string
+	}{
+		{"extension", "xrdebug.ini", "port=1", "must be .json, .yaml, .yml or .toml"},
+		{"json nested", "xrdebug.json", `{"port": {"a": 1}}`, "must be a scalar"},
+		{"yaml nested", "xrdebug.yaml", "server:\n  port: 1\n", "line 1: nested values are not supported"},
+		{"yaml list", "xrdebug.yaml", "- a\n", "line 1: nested values are not supported"},
+		{"toml table", "xrdebug.toml", "[server]\nport = 1\n", "line 1: nested values are not supported"},
+		{"toml bare string", "xrdebug.toml", "address = localhost\n", "line 1: unsupported value"},
+		{"unterminated", "xrdebug.toml", "address = \"localhost\n", "line 1: unterminated string"},
+		{"duplicate", "xrdebug.yaml", "port: 1\nport: 2\n", "line 2: duplicate key `port`"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfig(writeConfig(t, tt.file, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("Expected error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
+
+func TestParseOptionsConfig(t *testing.T) {
+	path := writeConfig(t, "xrdebug.yaml", "address: 0.0.0.0\nport: 9000\nheartbeat: 5s\n")
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	options, err := ParseOptions(set, testFlags(), []string{"-config", path, "-p", "9001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options.Port != 9001 {
+		t.Errorf("Expected flag to take precedence, got port %d", options.Port)
+	}
+	if options.Address != "0.0.0.0" || options.Heartbeat != 5*time.Second {
+		t.Errorf("Expected config values, got %s %s", options.Address, options.Heartbeat)
+	}
+	if options.TLSCert != "" {
+		t.Errorf("Expected default value, got %q", options.TLSCert)
+	}
+}
+
+func TestParseOptionsConfigErrors(t *testing.T) {
+	path := writeConfig(t, "xrdebug.json", `{"port": "high", "heartbeat": 5, "unknown": true, "config": "x.json"}`)
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	_, err := ParseOptions(set, testFlags(), []string{"-config", path})
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+	for _, expected := range []string{
+		"Invalid value for `heartbeat` in config file",
+		"Invalid value for `port` in config file",
+		"Unknown key `config` in config file",
+		"Unknown key `unknown` in config file",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error containing %q, got %v", expected, err)
+		}
+	}
+}
+
+func TestWriteConfig(t *testing.T) {
+	options := Options{Address: "localhost", Port: 8080, Heartbeat: 15 * time.Second, Config: "x.json"}
+	var buf bytes.Buffer
+	if err := WriteConfig(&buf, testFlags(), options); err != nil {
+		t.Fatal(err)
+	}
+	path := writeConfig(t, "xrdebug.json", buf.String())
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	got, err := ParseOptions(set, testFlags(), []string{"-config", path})
+	if err != nil {
+		t.Fatalf("Expected written config to load, got %v", err)
+	}
+	options.Config = path
+	if !reflect.DeepEqual(got, options) {
+		t.Errorf("Expected %+v, got %+v", options, got)
+	}
+}
+
+func TestWriteConfigSecrets(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteConfig(&buf, testFlags(), Options{UIToken: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "ui_token") || strings.Contains(buf.String(), "secret") {
+		t.Errorf("Expected the UI token to be left out, got %s", buf.String())
+	}
+}
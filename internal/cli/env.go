@@ -0,0 +1,84 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix is the prefix of the environment variables mapped to flags
+const EnvPrefix = "XRDEBUG_"
+
+// envExcluded lists the Options fields that can't be set from the environment
+var envExcluded = map[string]bool{
+	"PrintConfig": true,
+	"Version":     true,
+}
+
+// EnvKey returns the environment variable for a flag variable, which is the
+// variable in upper snake case with the EnvPrefix (`TLSCert` is
+// `XRDEBUG_TLS_CERT`).
+func EnvKey(variable string) string {
+	return EnvPrefix + strings.ToUpper(ConfigKey(variable))
+}
+
+// applyEnv sets the Options fields from the environment variables returned
+// by lookup, skipping the flags in explicit. Empty variables are ignored.
+// The flags set are added to explicit and every invalid value is returned.
+func applyEnv(fields reflect.Value, flags map[string]Flag, lookup func(string) (string, bool), explicit map[string]bool) []error {
+	var errs []error
+	for _, name := range sortedNames(flags) {
+		item := flags[name]
+		if envExcluded[item.Variable] || explicit[name] {
+			continue
+		}
+		key := EnvKey(item.Variable)
+		raw, found := lookup(key)
+		if !found || raw == "" {
+			continue
+		}
+		value, err := parseEnvValue(raw, item.Type)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Invalid value for `%s` environment variable: %v", key, err))
+			continue
+		}
+		fields.FieldByName(item.Variable).Set(reflect.ValueOf(value))
+		explicit[name] = true
+	}
+	return errs
+}
+
+// parseEnvValue parses an environment variable value as the given flag type.
+func parseEnvValue(raw string, flagType string) (any, error) {
+	switch flagType {
+	case "string":
+		return raw, nil
+	case "bool":
+		if b, err := strconv.ParseBool(raw); err == nil {
+			return b, nil
+		}
+		return nil, fmt.Errorf("must be a boolean")
+	case "int":
+		if i, err := strconv.Atoi(raw); err == nil {
+			return i, nil
+		}
+		return nil, fmt.Errorf("must be an integer")
+	case "duration":
+		if d, err := time.ParseDuration(raw); err == nil {
+			return d, nil
+		}
+		return nil, fmt.Errorf("must be a duration such as \"10s\"")
+	}
+	return nil, fmt.Errorf("unsupported type %s", flagType)
+}
@@ -0,0 +1,77 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package cli
+
+import (
+	"flag"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnvKey(t *testing.T) {
+	tests := map[string]string{
+		"Port":                   "XRDEBUG_PORT",
+		"TLSCert":                "XRDEBUG_TLS_CERT",
+		"EnableSignVerification": "XRDEBUG_ENABLE_SIGN_VERIFICATION",
+	}
+	for variable, expected := range tests {
+		if got := EnvKey(variable); got != expected {
+			t.Errorf("EnvKey(%q) = %q, want %q", variable, got, expected)
+		}
+	}
+}
+
+func TestParseOptionsEnv(t *testing.T) {
+	path := writeConfig(t, "xrdebug.toml", "address = \"0.0.0.0\"\nport = 9000\nheartbeat = \"5s\"\n")
+	t.Setenv("XRDEBUG_CONFIG", path)
+	t.Setenv("XRDEBUG_ADDRESS", "127.0.0.1")
+	t.Setenv("XRDEBUG_PORT", "9001")
+	t.Setenv("XRDEBUG_ENABLE_ENCRYPTION", "true")
+	t.Setenv("XRDEBUG_TLS_CERT", "")
+	t.Setenv("XRDEBUG_PRINT_CONFIG", "true")
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	options, err := ParseOptions(set, testFlags(), []string{"-p", "9002"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options.Port != 9002 {
+		t.Errorf("Expected flag to take precedence, got port %d", options.Port)
+	}
+	if options.Address != "127.0.0.1" || !options.EnableEncryption {
+		t.Errorf("Expected environment values, got %s %v", options.Address, options.EnableEncryption)
+	}
+	if options.Heartbeat != 5*time.Second {
+		t.Errorf("Expected config value, got %s", options.Heartbeat)
+	}
+	if options.TLSCert != "" || options.PrintConfig {
+		t.Errorf("Expected default values, got %q %v", options.TLSCert, options.PrintConfig)
+	}
+}
+
+func TestParseOptionsEnvErrors(t *testing.T) {
+	t.Setenv("XRDEBUG_PORT", "high")
+	t.Setenv("XRDEBUG_ENABLE_ENCRYPTION", "yes")
+	t.Setenv("XRDEBUG_HEARTBEAT", "5")
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	_, err := ParseOptions(set, testFlags(), nil)
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+	for _, expected := range []string{
+		"Invalid value for `XRDEBUG_PORT` environment variable: must be an integer",
+		"Invalid value for `XRDEBUG_ENABLE_ENCRYPTION` environment variable: must be a boolean",
+		"Invalid value for `XRDEBUG_HEARTBEAT` environment variable: must be a duration",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error containing %q, got %v", expected, err)
+		}
+	}
+}
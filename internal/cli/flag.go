@@ -12,6 +12,7 @@ package cli
 import (
 	"fmt"
 	"reflect"
+	"time"
 )
 
 // Flag represents a command line flag configuration.
@@ -23,11 +24,12 @@ type Flag struct {
 	Description string
 }
 
-// typeKindMap maps string type names to their reflect.Kind counterparts.
-var typeKindMap = map[string]reflect.Kind{
-	"string": reflect.String,
-	"int":    reflect.Int,
-	"bool":   reflect.Bool,
+// typeMap maps string type names to their reflect.Type counterparts.
+var typeMap = map[string]reflect.Type{
+	"string":   reflect.TypeOf(""),
+	"int":      reflect.TypeOf(0),
+	"bool":     reflect.TypeOf(false),
+	"duration": reflect.TypeOf(time.Duration(0)),
 }
 
 // NewFlag creates a Flag instance with validation.
@@ -42,11 +44,11 @@ func NewFlag(flag Flag) (*Flag, error) {
 		validationErrors = append(validationErrors,
 			fmt.Sprintf("Invalid variable for `%s` flag", flag.Name))
 	}
-	if _, exists := typeKindMap[flag.Type]; !exists {
+	if _, exists := typeMap[flag.Type]; !exists {
 		validationErrors = append(validationErrors,
 			fmt.Sprintf("Unsupported type for `%s` flag", flag.Name))
 	}
-	if reflect.TypeOf(flag.Default).Kind() != typeKindMap[flag.Type] {
+	if reflect.TypeOf(flag.Default) != typeMap[flag.Type] {
 		validationErrors = append(validationErrors,
 			fmt.Sprintf("Invalid default value for `%s` flag", flag.Name))
 	}
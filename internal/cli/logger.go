@@ -9,7 +9,18 @@
 
 package cli
 
-import "log"
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// LogFormats lists the supported structured log formats
+var LogFormats = []string{"text", "json"}
 
 // Logger defines the interface for logging operations
 type Logger interface {
@@ -17,6 +28,13 @@ type Logger interface {
 	Printf(format string, v ...interface{})
 }
 
+// LevelLogger is a Logger that also writes leveled records with structured fields
+type LevelLogger interface {
+	Logger
+	// Log writes msg at the given level, args are key-value pairs as in log/slog
+	Log(level slog.Level, msg string, args ...any)
+}
+
 // stdLogger implements the Logger interface using the standard log package
 type stdLogger struct{}
 
@@ -28,3 +46,74 @@ func (l *stdLogger) Printf(format string, v ...interface{}) {
 func NewLogger() Logger {
 	return &stdLogger{}
 }
+
+// slogLogger implements the LevelLogger interface using log/slog
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (l *slogLogger) Printf(format string, v ...interface{}) {
+	l.logger.Info(fmt.Sprintf(format, v...))
+}
+
+func (l *slogLogger) Log(level slog.Level, msg string, args ...any) {
+	l.logger.Log(context.Background(), level, msg, args...)
+}
+
+// NewStructuredLogger creates a LevelLogger writing to w in the given format
+// (text or json), discarding the records below level (debug, info, warn or error).
+func NewStructuredLogger(w io.Writer, format string, level string) (LevelLogger, error) {
+	var minLevel slog.Level
+	if err := minLevel.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("log level '%s' not supported", level)
+	}
+	options := &slog.HandlerOptions{Level: minLevel}
+	var handler slog.Handler
+	switch format {
+	case "text":
+		handler = slog.NewTextHandler(w, options)
+	case "json":
+		handler = slog.NewJSONHandler(w, options)
+	default:
+		return nil, fmt.Errorf("log format '%s' not supported", format)
+	}
+	return &slogLogger{logger: slog.New(handler)}, nil
+}
+
+// Debug logs msg with its fields at debug level
+func Debug(logger Logger, msg string, args ...any) {
+	logAt(logger, slog.LevelDebug, msg, args...)
+}
+
+// Info logs msg with its fields at info level
+func Info(logger Logger, msg string, args ...any) {
+	logAt(logger, slog.LevelInfo, msg, args...)
+}
+
+// Warn logs msg with its fields at warn level
+func Warn(logger Logger, msg string, args ...any) {
+	logAt(logger, slog.LevelWarn, msg, args...)
+}
+
+// Error logs msg with its fields at error level
+func Error(logger Logger, msg string, args ...any) {
+	logAt(logger, slog.LevelError, msg, args...)
+}
+
+// logAt writes the record to a LevelLogger, or falls back to Printf with
+// the fields appended as `key=value` pairs for any other Logger.
+func logAt(logger Logger, level slog.Level, msg string, args ...any) {
+	if l, ok := logger.(LevelLogger); ok {
+		l.Log(level, msg, args...)
+		return
+	}
+	var line strings.Builder
+	line.WriteString(msg)
+	record := slog.NewRecord(time.Time{}, level, msg, 0)
+	record.Add(args...)
+	record.Attrs(func(attr slog.Attr) bool {
+		fmt.Fprintf(&line, " %s=%v", attr.Key, attr.Value)
+		return true
+	})
+	logger.Printf("%s", line.String())
+}
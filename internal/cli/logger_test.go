@@ -2,6 +2,8 @@ package cli
 
 import (
 	"bytes"
+	"encoding/json"
+	"io"
 	"log"
 	"os"
 	"strings"
@@ -22,3 +24,62 @@ func TestLoggerPrintf(t *testing.T) {
 		t.Errorf("Printf() output = %v, want %v", output, expected)
 	}
 }
+
+func TestNewStructuredLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewStructuredLogger(&buf, "json", "info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	Debug(logger, "Hidden")
+	Info(logger, "Message", "remote_addr", "127.0.0.1:1234", "bytes", 10)
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a single JSON record, got %q", buf.String())
+	}
+	expected := map[string]any{
+		"level":       "INFO",
+		"msg":         "Message",
+		"remote_addr": "127.0.0.1:1234",
+		"bytes":       float64(10),
+	}
+	for key, value := range expected {
+		if record[key] != value {
+			t.Errorf("Expected %s %v, got %v", key, value, record[key])
+		}
+	}
+	buf.Reset()
+	logger.Printf("test message %s", "arg")
+	if !strings.Contains(buf.String(), `"msg":"test message arg"`) {
+		t.Errorf("Expected Printf record, got %q", buf.String())
+	}
+}
+
+func TestNewStructuredLoggerErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		level  string
+	}{
+		{"invalid format", "xml", "info"},
+		{"invalid level", "text", "verbose"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewStructuredLogger(io.Discard, tt.format, tt.level); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestLevelFallback(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	Warn(NewLogger(), "Evicted", "remote_addr", "127.0.0.1:1234", "error", "queue full")
+	expected := "Evicted remote_addr=127.0.0.1:1234 error=queue full"
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
@@ -10,8 +10,12 @@
 package cli
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"os"
+	"reflect"
+	"time"
 )
 
 // Options holds the configuration for the CLI application.
@@ -32,51 +36,143 @@ type Options struct {
 	EnableSignVerification bool
 	// SignPrivateKey is the path to the private key used for signing (ed25519)
 	SignPrivateKey string
+	// TrustedKeys is the path to the trusted client public keys, either a
+	// file or a directory
+	TrustedKeys string
+	// UIToken is the access token required for the user interface routes
+	UIToken string
+	// UISessionTTL is the expiration of the user interface login sessions
+	UISessionTTL time.Duration
+	// ReloadInterval is the interval for checking the key and certificate
+	// files for changes
+	ReloadInterval time.Duration
+	// SignMaxSkew is the maximum clock skew of the signature timestamps
+	SignMaxSkew time.Duration
+	// SignNonceCache is the number of signature nonces remembered
+	SignNonceCache int
+	// SignLegacy accepts the legacy signature version
+	SignLegacy bool
 	// SessionName specifies the name of the debug session
 	SessionName string
+	// MaxSessions limits the number of debug sessions
+	MaxSessions int
+	// SessionIdleTTL is the idle time before removing a session
+	SessionIdleTTL time.Duration
 	// Editor specifies the preferred text editor
 	Editor string
+	// MaxBodySize is the maximum request body size in bytes
+	MaxBodySize int
+	// HistorySize is the maximum number of messages kept for replay
+	HistorySize int
+	// HistoryAge is the maximum age of the messages kept for replay
+	HistoryAge time.Duration
+	// QueueSize is the number of events queued for each stream client
+	QueueSize int
+	// QueuePolicy determines what happens when a stream client falls behind
+	QueuePolicy string
+	// Heartbeat is the interval between stream pings
+	Heartbeat time.Duration
+	// WriteTimeout is the timeout for each stream write
+	WriteTimeout time.Duration
+	// LogFormat is the log output format (text or json)
+	LogFormat string
+	// LogLevel is the minimum level of the logged records
+	LogLevel string
+	// AccessLog is the path to the access log file, empty logs to stderr
+	AccessLog string
+	// AccessLogMaxSize is the size in megabytes for rotating the access log file
+	AccessLogMaxSize int
+	// AccessLogBackups is the number of rotated access log files to keep
+	AccessLogBackups int
+	// AccessLogStream determines if the stream requests are logged
+	AccessLogStream bool
+	// ShutdownTimeout is the maximum time for the graceful shutdown
+	ShutdownTimeout time.Duration
+	// PauseTTL is the default expiration of the pause locks
+	PauseTTL time.Duration
+	// PauseCleanup is the interval for removing expired pause locks
+	PauseCleanup time.Duration
+	// Config is the path to the config file [JSON, YAML or TOML]
+	Config string
+	// PrintConfig prints the effective configuration and exits
+	PrintConfig bool
 	// Version specifies the `-version` flag to return the version
 	Version bool
 }
 
 // NewOptions creates a new Options instance from the provided flags configuration.
 // It parses command-line flags and returns Options and any error encountered.
-// The flags parameter should contain the flag definitions for all supported options.
+// The flags parameter should contain the flag definitions for all supported options,
+// each flag Variable must match an Options field of the same type.
 func NewOptions(flags map[string]Flag) (Options, error) {
+	return ParseOptions(flag.CommandLine, flags, os.Args[1:])
+}
+
+// ParseOptions defines the flags in set and parses args into Options.
+// See Parse for the precedence of the sources.
+func ParseOptions(set *flag.FlagSet, flags map[string]Flag, args []string) (Options, error) {
+	var options Options
+	if err := Parse(set, flags, args, &options); err != nil {
+		return Options{}, err
+	}
+	return options, nil
+}
+
+// Parse defines the flags in set and parses args into target, a pointer to
+// a struct whose fields match the flag variables. Flags not present in args
+// are read from their environment variable (see EnvKey) and then from the
+// config file named by the `Config` field, so the precedence is flags >
+// environment > file > defaults. Every invalid flag definition, environment
+// variable or config value is reported at once.
+func Parse(set *flag.FlagSet, flags map[string]Flag, args []string, target any) error {
 	var validationErrors []error
+	fields := reflect.ValueOf(target).Elem()
 	flagValues := make(map[string]interface{})
-	for name, item := range flags {
+	for _, name := range sortedNames(flags) {
+		item := flags[name]
 		item.Name = name
 		flagItem, err := NewFlag(item)
 		if err != nil {
 			validationErrors = append(validationErrors, err)
 			continue
 		}
+		field := fields.FieldByName(flagItem.Variable)
+		if !field.IsValid() || field.Type() != typeMap[flagItem.Type] {
+			validationErrors = append(validationErrors,
+				fmt.Errorf("Invalid variable for `%s` flag", name))
+			continue
+		}
 		switch flagItem.Type {
 		case "string":
-			flagValues[item.Variable] = flag.String(name, item.Default.(string), item.Description)
+			flagValues[item.Variable] = set.String(name, item.Default.(string), item.Description)
 		case "int":
-			flagValues[item.Variable] = flag.Int(name, item.Default.(int), item.Description)
+			flagValues[item.Variable] = set.Int(name, item.Default.(int), item.Description)
 		case "bool":
-			flagValues[item.Variable] = flag.Bool(name, item.Default.(bool), item.Description)
+			flagValues[item.Variable] = set.Bool(name, item.Default.(bool), item.Description)
+		case "duration":
+			flagValues[item.Variable] = set.Duration(name, item.Default.(time.Duration), item.Description)
 		}
 	}
 	if len(validationErrors) > 0 {
-		return Options{}, fmt.Errorf("%v", validationErrors)
+		return fmt.Errorf("%v", validationErrors)
+	}
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+	for variable, value := range flagValues {
+		fields.FieldByName(variable).Set(reflect.ValueOf(value).Elem())
+	}
+	explicit := make(map[string]bool)
+	set.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	errs := applyEnv(fields, flags, os.LookupEnv, explicit)
+	if config := fields.FieldByName("Config"); config.IsValid() && config.String() != "" {
+		values, err := LoadConfig(config.String())
+		if err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		errs = append(errs, applyConfig(fields, flags, values, explicit)...)
 	}
-	flag.Parse()
-	return Options{
-		Address:                *flagValues["Address"].(*string),
-		Port:                   *flagValues["Port"].(*int),
-		TLSCert:                *flagValues["TLSCert"].(*string),
-		TLSPrivateKey:          *flagValues["TLSPrivateKey"].(*string),
-		EnableEncryption:       *flagValues["EnableEncryption"].(*bool),
-		SymmetricKey:           *flagValues["SymmetricKey"].(*string),
-		EnableSignVerification: *flagValues["EnableSignVerification"].(*bool),
-		SignPrivateKey:         *flagValues["SignPrivateKey"].(*string),
-		SessionName:            *flagValues["SessionName"].(*string),
-		Editor:                 *flagValues["Editor"].(*string),
-		Version:                *flagValues["Version"].(*bool),
-	}, nil
+	return errors.Join(errs...)
 }
@@ -13,42 +13,152 @@ package message
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/xrdebug/xrdebug/internal/cli"
 	"github.com/xrdebug/xrdebug/internal/dump"
+	"github.com/xrdebug/xrdebug/internal/form"
+	"github.com/xrdebug/xrdebug/internal/identity"
+	"github.com/xrdebug/xrdebug/internal/metrics"
 )
 
 var (
-	errParseForm = "Error parsing form data"
-	errEmptyForm = "Form data is empty"
+	errParseForm     = "Error parsing form data"
+	errEmptyForm     = "Form data is empty"
+	errInvalidFields = "Invalid fields"
+	errParseBatch    = "Error parsing batch"
+	errEmptyBatch    = "Batch is empty"
 )
 
-// Handle returns an http.HandlerFunc that handles incoming debug messages.
-// It takes a messages channel where the processed debug messages will be sent,
-// and a logger for logging the received messages.
+// BatchError reports a rejected batch item by its position.
+type BatchError struct {
+	Index  int               `json:"index"`
+	Error  string            `json:"error"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// BatchResult reports the outcome of a batch request.
+type BatchResult struct {
+	Accepted int          `json:"accepted"`
+	Rejected int          `json:"rejected"`
+	Errors   []BatchError `json:"errors"`
+}
+
+// Handle returns an http.HandlerFunc that handles incoming debug messages,
+// sent either url-encoded or as a JSON object. It takes a messages channel
+// where the processed debug messages will be sent, and a logger for logging
+// the received messages. Invalid fields are reported as a JSON error.
 func Handle(messages chan string, logger cli.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if err := r.ParseForm(); err != nil {
-			http.Error(w, errParseForm, http.StatusBadRequest)
+		start := time.Now()
+		if err := form.Parse(r); err != nil {
+			form.WriteError(w, err, http.StatusBadRequest, errParseForm)
 			return
 		}
 		if len(r.Form) == 0 {
-			http.Error(w, errEmptyForm, http.StatusBadRequest)
+			form.WriteError(w, nil, http.StatusBadRequest, errEmptyForm)
 			return
 		}
-		msg := dump.New(
-			"message",
-			r.FormValue("body"),
-			r.FormValue("file_path"),
-			r.FormValue("file_line"),
-			r.FormValue("emote"),
-			r.FormValue("topic"),
-			r.FormValue("id"),
-		)
+		if err := dump.Validate(r.Form); err != nil {
+			form.WriteError(w, err, http.StatusBadRequest, errInvalidFields)
+			return
+		}
+		msg := newDump(r.Form)
 		jsonMsg, _ := json.Marshal(msg)
 		messages <- string(jsonMsg)
+		metrics.MessagesReceived.Inc()
 		w.WriteHeader(http.StatusOK)
-		logger.Printf("Message %s %s", r.RemoteAddr, msg.FileDisplay)
+		cli.Info(logger, "Message", identity.Fields(r.Context(),
+			"remote_addr", r.RemoteAddr,
+			"route", r.Pattern,
+			"message_id", msg.ID,
+			"topic", msg.Topic,
+			"file", msg.FileDisplay,
+			"bytes", len(jsonMsg),
+			"latency", time.Since(start))...)
+	}
+}
+
+// Batch returns an http.HandlerFunc that handles a batch of debug messages,
+// sent as a JSON array or as NDJSON (one JSON object per line).
+// Valid items are sent in order while the rejected ones are reported back.
+func Batch(messages chan string, logger cli.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		isBatch, err := form.IsBatch(r)
+		if err != nil || !isBatch {
+			form.WriteError(w, err, http.StatusBadRequest, errParseBatch)
+			return
+		}
+		items, err := form.Batch(r)
+		if err != nil {
+			form.WriteError(w, err, http.StatusBadRequest, errParseBatch)
+			return
+		}
+		if len(items) == 0 {
+			form.WriteError(w, nil, http.StatusBadRequest, errEmptyBatch)
+			return
+		}
+		result := BatchResult{Errors: []BatchError{}}
+		for i, item := range items {
+			if item.Err == nil && len(item.Values) == 0 {
+				item.Err = errors.New(errEmptyForm)
+			}
+			if item.Err == nil {
+				item.Err = dump.Validate(item.Values)
+			}
+			if item.Err != nil {
+				batchErr := BatchError{Index: i, Error: item.Err.Error()}
+				var validationErr *dump.ValidationError
+				if errors.As(item.Err, &validationErr) {
+					batchErr.Error = errInvalidFields
+					batchErr.Fields = validationErr.Fields
+				}
+				result.Rejected++
+				result.Errors = append(result.Errors, batchErr)
+				continue
+			}
+			jsonMsg, _ := json.Marshal(newDump(item.Values))
+			messages <- string(jsonMsg)
+			result.Accepted++
+		}
+		metrics.MessagesReceived.Add(float64(result.Accepted))
+		if result.Accepted == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		json.NewEncoder(w).Encode(result)
+		cli.Info(logger, "Batch", identity.Fields(r.Context(),
+			"remote_addr", r.RemoteAddr,
+			"route", r.Pattern,
+			"accepted", result.Accepted,
+			"rejected", result.Rejected,
+			"latency", time.Since(start))...)
+	}
+}
+
+// newDump creates a message dump from the request fields.
+func newDump(values url.Values) *dump.Dump {
+	return dump.New(
+		"message",
+		values.Get("body"),
+		values.Get("file_path"),
+		values.Get("file_line"),
+		values.Get("emote"),
+		values.Get("topic"),
+		values.Get("id"),
+	)
+}
+
+// Clear returns an http.HandlerFunc that broadcasts a clear message,
+// which also resets the message history.
+func Clear(messages chan string, logger cli.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jsonMsg, _ := json.Marshal(dump.New("clear", "", "", "", "", "", ""))
+		messages <- string(jsonMsg)
+		w.WriteHeader(http.StatusNoContent)
+		cli.Info(logger, "Clear", identity.Fields(r.Context(), "remote_addr", r.RemoteAddr, "route", r.Pattern)...)
 	}
 }
@@ -10,12 +10,15 @@
 package message
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
+
+	"github.com/xrdebug/xrdebug/internal/identity"
 )
 
 type mockLogger struct {
@@ -48,6 +51,16 @@ func TestMessage(t *testing.T) {
 			expectMessage:  true,
 			expectLog:      true,
 		},
+		{
+			name: "invalid file line",
+			formData: url.Values{
+				"body":      {"test message"},
+				"file_line": {"ten"},
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectMessage:  false,
+			expectLog:      false,
+		},
 		{
 			name:           "empty form",
 			formData:       url.Values{},
@@ -99,3 +112,172 @@ func TestMessage(t *testing.T) {
 		})
 	}
 }
+
+func TestClear(t *testing.T) {
+	messages := make(chan string, 1)
+	logger := &mockLogger{}
+	handler := Clear(messages, logger)
+	req := httptest.NewRequest(http.MethodDelete, "/messages", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusNoContent {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNoContent)
+	}
+	select {
+	case msg := <-messages:
+		if !strings.Contains(msg, `"action":"clear"`) {
+			t.Errorf("expected clear action, got %s", msg)
+		}
+	default:
+		t.Error("no message received from channel")
+	}
+}
+
+func TestMessageClient(t *testing.T) {
+	messages := make(chan string, 1)
+	logger := &mockLogger{}
+	handler := Handle(messages, logger)
+	req := httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader("body=test"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(identity.With(req.Context(), "laptop"))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if !strings.Contains(logger.lastMsg, "client=laptop") {
+		t.Errorf("Expected the client identity in the log, got %q", logger.lastMsg)
+	}
+}
+
+func TestMessageJSON(t *testing.T) {
+	messages := make(chan string, 1)
+	handler := Handle(messages, &mockLogger{})
+	body := `{"body":"test message","file_path":"test.go","file_line":10,"topic":"json"}`
+	req := httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	msg := <-messages
+	for _, expected := range []string{`"file_line":"10"`, `"topic":"json"`, `"file_display_short":"test.go:10"`} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("expected message to contain %s, got %s", expected, msg)
+		}
+	}
+}
+
+func TestBatch(t *testing.T) {
+	tests := []struct {
+		name           string
+		contentType    string
+		body           string
+		expectedStatus int
+		expected       BatchResult
+		bodies         []string
+	}{
+		{
+			name:           "json array",
+			contentType:    "application/json",
+			body:           `[{"body":"a"},{},{"body":"b","file_line":2},"c",{"file_line":"x"}]`,
+			expectedStatus: http.StatusOK,
+			expected:       BatchResult{Accepted: 2, Rejected: 3},
+			bodies:         []string{"a", "b"},
+		},
+		{
+			name:           "ndjson",
+			contentType:    "application/x-ndjson",
+			body:           "{\"body\":\"a\"}\n{\"body\":\"b\"}\n",
+			expectedStatus: http.StatusOK,
+			expected:       BatchResult{Accepted: 2},
+			bodies:         []string{"a", "b"},
+		},
+		{
+			name:           "all rejected",
+			contentType:    "application/json",
+			body:           `[{}]`,
+			expectedStatus: http.StatusBadRequest,
+			expected:       BatchResult{Rejected: 1},
+		},
+		{
+			name:           "not a batch",
+			contentType:    "application/json",
+			body:           `{"body":"a"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "empty batch",
+			contentType:    "application/json",
+			body:           `[]`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := make(chan string, 10)
+			handler := Batch(messages, &mockLogger{})
+			req := httptest.NewRequest(http.MethodPost, "/messages/batch", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", status, tt.expectedStatus)
+			}
+			if tt.expected.Accepted+tt.expected.Rejected == 0 {
+				return
+			}
+			var result BatchResult
+			if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+				t.Fatal(err)
+			}
+			if result.Accepted != tt.expected.Accepted || result.Rejected != tt.expected.Rejected {
+				t.Errorf("expected %+v, got %+v", tt.expected, result)
+			}
+			if len(result.Errors) != result.Rejected {
+				t.Errorf("expected %d errors, got %v", result.Rejected, result.Errors)
+			}
+			close(messages)
+			var i int
+			for msg := range messages {
+				if !strings.Contains(msg, `"message":"`+tt.bodies[i]+`"`) {
+					t.Errorf("expected message %d to be %s, got %s", i, tt.bodies[i], msg)
+				}
+				i++
+			}
+		})
+	}
+}
+
+func TestMessageValidationResponse(t *testing.T) {
+	handler := Handle(make(chan string, 1), &mockLogger{})
+	body := `{"body":"test","file_line":"ten","topic":"` + strings.Repeat("a", 200) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	var response struct {
+		Error  string            `json:"error"`
+		Fields map[string]string `json:"fields"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"file_line", "topic"} {
+		if _, ok := response.Fields[field]; !ok {
+			t.Errorf("expected %s in invalid fields, got %v", field, response.Fields)
+		}
+	}
+}
+
+func TestMessageBodyTooLarge(t *testing.T) {
+	handler := Handle(make(chan string, 1), &mockLogger{})
+	req := httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader("body="+strings.Repeat("a", 100)))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	req.Body = http.MaxBytesReader(rr, req.Body, 10)
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusRequestEntityTooLarge)
+	}
+}
@@ -10,15 +10,29 @@
 package pause
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/xrdebug/xrdebug/internal/cli"
 	"github.com/xrdebug/xrdebug/internal/dump"
+	"github.com/xrdebug/xrdebug/internal/form"
+	"github.com/xrdebug/xrdebug/internal/identity"
+	"github.com/xrdebug/xrdebug/internal/metrics"
 	"github.com/xrdebug/xrdebug/internal/pausectl"
 )
 
+// MaxWait is the maximum duration of a GET /pauses/{id}?wait request
+const MaxWait = time.Minute
+
+// MaxTTL is the maximum expiration requested with the `ttl` field
+const MaxTTL = 24 * time.Hour
+
 // Controller handles HTTP requests for pause operations.
 // It manages pause locks and messaging for debugging sessions.
 type Controller struct {
@@ -36,12 +50,25 @@ func New(lockManager *pausectl.Manager, messages chan string, logger cli.Logger)
 	}
 }
 
-// Post handles POST /pauses requests.
+// Post handles POST /pauses requests, sent either url-encoded or as a JSON object.
 // It creates a new pause lock and broadcasts the pause message.
 func (c *Controller) Post() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if err := form.Parse(r); err != nil {
+			form.WriteError(w, err, http.StatusBadRequest, "Error parsing form data")
+			return
+		}
+		if err := dump.Validate(r.Form, "id"); err != nil {
+			form.WriteError(w, err, http.StatusBadRequest, "Invalid fields")
+			return
+		}
+		ttl, err := parseTTL(r.FormValue("ttl"))
+		if err != nil {
+			form.WriteError(w, err, http.StatusBadRequest, "Invalid fields")
+			return
+		}
 		id := r.FormValue("id")
-		lock, err := c.lockManager.New(id)
+		lock, err := c.lockManager.NewWithTTL(id, ttl)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusConflict)
 			return
@@ -55,21 +82,61 @@ func (c *Controller) Post() http.HandlerFunc {
 			r.FormValue("topic"),
 			id,
 		)
-		c.logger.Printf("Pause %s %s", r.RemoteAddr, msg.FileDisplay)
+		cli.Info(c.logger, "Pause", identity.Fields(r.Context(),
+			"remote_addr", r.RemoteAddr,
+			"route", r.Pattern,
+			"message_id", id,
+			"topic", msg.Topic,
+			"file", msg.FileDisplay)...)
 		jsonMsg, _ := json.Marshal(msg)
 		c.messages <- string(jsonMsg)
-		w.Header().Set("Location", fmt.Sprintf("/pauses/%s", id))
+		metrics.Pauses.Inc("created")
+		// The lock lives under the requested path, which includes the session
+		w.Header().Set("Location", strings.TrimSuffix(r.URL.EscapedPath(), "/")+"/"+url.PathEscape(id))
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(lock)
 	}
 }
 
+// parseTTL parses the `ttl` field, either a duration such as `90s` or a
+// number of seconds. It returns zero for the default expiration.
+func parseTTL(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(value)
+	if seconds, convErr := strconv.Atoi(value); convErr == nil {
+		ttl, err = time.Duration(seconds)*time.Second, nil
+	}
+	if err != nil || ttl <= 0 || ttl > MaxTTL {
+		return 0, &dump.ValidationError{Fields: map[string]string{
+			"ttl": fmt.Sprintf("must be a duration between 1s and %s", MaxTTL),
+		}}
+	}
+	return ttl, nil
+}
+
 // Get handles GET /pauses/{id} requests.
-// It retrieves the status of an existing pause lock.
+// It retrieves the status of an existing pause lock. With the `wait` query
+// parameter (a duration such as `30s`, up to MaxWait) it blocks until the
+// lock is stopped or removed, returning the current status on timeout.
 func (c *Controller) Get() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("id")
-		lock, err := c.lockManager.Get(id)
+		var lock *pausectl.Lock
+		var err error
+		if param := r.URL.Query().Get("wait"); param != "" {
+			wait, parseErr := time.ParseDuration(param)
+			if parseErr != nil || wait < 0 {
+				http.Error(w, "Invalid wait duration", http.StatusBadRequest)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), min(wait, MaxWait))
+			defer cancel()
+			lock, err = c.lockManager.Wait(ctx, id)
+		} else {
+			lock, err = c.lockManager.Get(id)
+		}
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
@@ -88,7 +155,9 @@ func (c *Controller) Patch() http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
-		c.logger.Printf("Stop %s", r.RemoteAddr)
+		cli.Info(c.logger, "Stop", "remote_addr", r.RemoteAddr, "route", r.Pattern, "message_id", id)
+		c.broadcast("pause-stop", id)
+		metrics.Pauses.Inc("stopped")
 		json.NewEncoder(w).Encode(lock)
 	}
 }
@@ -103,7 +172,29 @@ func (c *Controller) Delete() http.HandlerFunc {
 			return
 		}
 		c.lockManager.Delete(id)
-		c.logger.Printf("Continue %s", r.RemoteAddr)
+		cli.Info(c.logger, "Continue", "remote_addr", r.RemoteAddr, "route", r.Pattern, "message_id", id)
+		c.broadcast("pause-continue", id)
+		metrics.Pauses.Inc("continued")
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
+
+// Refresh handles POST /pauses/{id}/refresh requests.
+// It restarts the expiration of a pause lock being inspected.
+func (c *Controller) Refresh() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		lock, err := c.lockManager.Refresh(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(lock)
+	}
+}
+
+// broadcast notifies the clients about a change on the pause lock.
+func (c *Controller) broadcast(action, id string) {
+	jsonMsg, _ := json.Marshal(dump.New(action, "", "", "", "", "", id))
+	c.messages <- string(jsonMsg)
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -78,6 +80,67 @@ func TestPauseController(t *testing.T) {
 		}
 		controller.lockManager.Delete(lockID)
 	})
+	t.Run("POST create lock JSON", func(t *testing.T) {
+		body := strings.NewReader(`{"id":"` + lockID + `","body":"test","file_line":1}`)
+		req := httptest.NewRequest(http.MethodPost, "/pauses", body)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		controller.Post()(w, req)
+		if w.Code != http.StatusCreated {
+			t.Errorf("Expected status %d, got %d", http.StatusCreated, w.Code)
+		}
+		assertBroadcast(t, messages, "pause", lockID)
+		controller.lockManager.Delete(lockID)
+	})
+	t.Run("POST location", func(t *testing.T) {
+		tests := []struct {
+			path     string
+			id       string
+			expected string
+		}{
+			{"/pauses", lockID, "/pauses/" + lockID},
+			{"/sessions/team/pauses", lockID, "/sessions/team/pauses/" + lockID},
+			{"/sessions/my%20team/pauses", "a/b", "/sessions/my%20team/pauses/a%2Fb"},
+		}
+		for _, tt := range tests {
+			body := strings.NewReader(url.Values{"id": {tt.id}, "body": {"test"}}.Encode())
+			req := httptest.NewRequest(http.MethodPost, tt.path, body)
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			controller.Post()(w, req)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("Expected status %d, got %d", http.StatusCreated, w.Code)
+			}
+			if got := w.Header().Get("Location"); got != tt.expected {
+				t.Errorf("Expected location %s, got %s", tt.expected, got)
+			}
+			<-messages
+			controller.lockManager.Delete(tt.id)
+		}
+	})
+	t.Run("POST missing id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/pauses", strings.NewReader("body=test&file_line=x"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		controller.Post()(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+		for _, field := range []string{`"id"`, `"file_line"`} {
+			if !strings.Contains(w.Body.String(), field) {
+				t.Errorf("Expected %s in response, got %s", field, w.Body.String())
+			}
+		}
+	})
+	t.Run("POST invalid JSON", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/pauses", strings.NewReader(`{"id":`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		controller.Post()(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
 	t.Run("GET existing lock", func(t *testing.T) {
 		_, err := controller.lockManager.New(lockID)
 		if err != nil {
@@ -109,6 +172,7 @@ func TestPauseController(t *testing.T) {
 		if w.Code != http.StatusOK {
 			t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
 		}
+		assertBroadcast(t, messages, "pause-stop", lockID)
 		controller.lockManager.Delete(lockID)
 	})
 	t.Run("DELETE existing lock", func(t *testing.T) {
@@ -123,5 +187,124 @@ func TestPauseController(t *testing.T) {
 		if w.Code != http.StatusNoContent {
 			t.Errorf("Expected status %d, got %d", http.StatusNoContent, w.Code)
 		}
+		assertBroadcast(t, messages, "pause-continue", lockID)
+	})
+}
+
+// assertBroadcast verifies the next message sent matches the given action and lock ID
+func assertBroadcast(t *testing.T, messages chan string, action, id string) {
+	t.Helper()
+	select {
+	case msg := <-messages:
+		var got struct {
+			Action string `json:"action"`
+			ID     string `json:"id"`
+		}
+		if err := json.Unmarshal([]byte(msg), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Action != action || got.ID != id {
+			t.Errorf("Expected %s for %s, got %s for %s", action, id, got.Action, got.ID)
+		}
+	default:
+		t.Errorf("Expected %s message to be sent", action)
+	}
+}
+
+func TestPauseControllerWait(t *testing.T) {
+	controller, _ := setupTest()
+	get := func(id, wait string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/pauses/"+id+"?wait="+wait, nil)
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		controller.Get()(w, req)
+		return w
+	}
+	if _, err := controller.lockManager.New("wait"); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("invalid wait", func(t *testing.T) {
+		for _, wait := range []string{"soon", "-1s"} {
+			if w := get("wait", wait); w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d for %s, got %d", http.StatusBadRequest, wait, w.Code)
+			}
+		}
+	})
+	t.Run("timeout returns current status", func(t *testing.T) {
+		w := get("wait", "10ms")
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"stop":false`) {
+			t.Errorf("Expected running lock, got %d %s", w.Code, w.Body.String())
+		}
+	})
+	t.Run("returns on stop", func(t *testing.T) {
+		time.AfterFunc(10*time.Millisecond, func() { controller.lockManager.Update("wait") })
+		start := time.Now()
+		w := get("wait", "5s")
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"stop":true`) {
+			t.Errorf("Expected stopped lock, got %d %s", w.Code, w.Body.String())
+		}
+		if time.Since(start) > time.Second {
+			t.Error("Expected wait to return on update")
+		}
 	})
+	t.Run("returns on delete", func(t *testing.T) {
+		controller.lockManager.Delete("wait")
+		controller.lockManager.New("wait")
+		time.AfterFunc(10*time.Millisecond, func() { controller.lockManager.Delete("wait") })
+		if w := get("wait", "5s"); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+}
+
+func TestPauseControllerTTL(t *testing.T) {
+	controller, messages := setupTest()
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/pauses", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		controller.Post()(w, req)
+		return w
+	}
+	tests := []struct {
+		ttl    string
+		status int
+	}{
+		{"90s", http.StatusCreated},
+		{"30", http.StatusCreated},
+		{"0", http.StatusBadRequest},
+		{"-1s", http.StatusBadRequest},
+		{"soon", http.StatusBadRequest},
+		{"25h", http.StatusBadRequest},
+	}
+	for i, tt := range tests {
+		id := "ttl-" + strconv.Itoa(i)
+		w := post("id=" + id + "&ttl=" + tt.ttl)
+		if w.Code != tt.status {
+			t.Errorf("Expected status %d for ttl %s, got %d", tt.status, tt.ttl, w.Code)
+		}
+		if tt.status == http.StatusCreated {
+			assertBroadcast(t, messages, "pause", id)
+		} else if !strings.Contains(w.Body.String(), `"ttl"`) {
+			t.Errorf("Expected ttl field error, got %s", w.Body.String())
+		}
+	}
+}
+
+func TestPauseControllerRefresh(t *testing.T) {
+	controller, _ := setupTest()
+	refresh := func(id string) int {
+		req := httptest.NewRequest(http.MethodPost, "/pauses/"+id+"/refresh", nil)
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		controller.Refresh()(w, req)
+		return w.Code
+	}
+	if code := refresh("missing"); code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, code)
+	}
+	controller.lockManager.New("refresh")
+	if code := refresh("refresh"); code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, code)
+	}
 }
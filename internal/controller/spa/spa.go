@@ -21,8 +21,14 @@ func Handle(content []byte) http.HandlerFunc {
 			http.NotFound(w, r)
 			return
 		}
-		w.Header().Set("Content-Type", "text/html")
-		w.Header().Set("Content-Encoding", "gzip")
-		w.Write(content)
+		Write(w, content)
 	}
 }
+
+// Write writes the gzipped SPA content with the appropriate headers,
+// for routes already matched by the caller.
+func Write(w http.ResponseWriter, content []byte) {
+	w.Header().Set("Content-Type", "text/html")
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Write(content)
+}
@@ -42,3 +42,15 @@ func TestSpaNotFound(t *testing.T) {
 		t.Errorf("Status Code = %d; want %d", got, http.StatusNotFound)
 	}
 }
+
+func TestWrite(t *testing.T) {
+	content := []byte("Test Content")
+	w := httptest.NewRecorder()
+	Write(w, content)
+	if got := w.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Errorf("Content-Encoding = %q; want gzip", got)
+	}
+	if got := w.Body.String(); got != string(content) {
+		t.Errorf("Body = %q; want %q", got, string(content))
+	}
+}
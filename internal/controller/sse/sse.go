@@ -11,34 +11,259 @@
 package sse
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/xrdebug/xrdebug/internal/cipher"
 	"github.com/xrdebug/xrdebug/internal/cli"
+	"github.com/xrdebug/xrdebug/internal/history"
+	"github.com/xrdebug/xrdebug/internal/metrics"
 )
 
-// Client represents a connected SSE client with its associated writer
-// and flusher for sending events.
+// Event types sent to the clients, these match the dump action.
+const (
+	EventMessage       = "message"
+	EventPause         = "pause"
+	EventPauseStop     = "pause-stop"
+	EventPauseContinue = "pause-continue"
+	EventPauseExpired  = "pause-expired"
+	EventClear         = "clear"
+	EventShutdown      = "shutdown"
+)
+
+// retryMilliseconds is the reconnection time hint sent to the clients.
+const retryMilliseconds = 3000
+
+var eventTypes = map[string]bool{
+	EventMessage:       true,
+	EventPause:         true,
+	EventPauseStop:     true,
+	EventPauseContinue: true,
+	EventPauseExpired:  true,
+	EventClear:         true,
+	EventShutdown:      true,
+}
+
+// Policy determines what happens when a client queue is full.
+type Policy string
+
+// Supported policies for clients falling behind.
+const (
+	// PolicyDropOldest discards the oldest queued event to make room
+	PolicyDropOldest Policy = "drop-oldest"
+	// PolicyDropNewest discards the event being sent
+	PolicyDropNewest Policy = "drop-newest"
+	// PolicyDisconnect disconnects the client
+	PolicyDisconnect Policy = "disconnect"
+)
+
+// Policies lists the supported policies.
+var Policies = []Policy{PolicyDropOldest, PolicyDropNewest, PolicyDisconnect}
+
+// ParsePolicy returns the Policy for the given name.
+func ParsePolicy(name string) (Policy, error) {
+	for _, policy := range Policies {
+		if string(policy) == name {
+			return policy, nil
+		}
+	}
+	return "", fmt.Errorf("queue policy '%s' not supported", name)
+}
+
+// Stats holds the counters for the clients falling behind.
+type Stats struct {
+	// Dropped is the number of events discarded by full queues
+	Dropped atomic.Uint64
+	// Disconnected is the number of clients disconnected by full queues
+	Disconnected atomic.Uint64
+}
+
+// Queue configures the per-client event queues.
+type Queue struct {
+	// Size is the number of events each client can hold
+	Size int
+	// Policy determines what to do when a client queue is full
+	Policy Policy
+	// Stats collects the queue counters
+	Stats *Stats
+}
+
+// Heartbeat configures the connection liveness checks.
+type Heartbeat struct {
+	// Interval between ping comments, zero disables the pings
+	Interval time.Duration
+	// WriteTimeout is the deadline for each write, zero disables the deadline
+	WriteTimeout time.Duration
+}
+
+// Client represents a connected SSE client with its associated writer,
+// controller and the queue of events waiting to be sent.
 type Client struct {
-	w       http.ResponseWriter
-	flusher http.Flusher
+	w         http.ResponseWriter
+	rc        *http.ResponseController
+	queue     chan history.Entry
+	done      chan struct{}
+	closeOnce sync.Once
+	dropped   atomic.Uint64
+	// last is the shutdown event, set before closing ending
+	last       history.Entry
+	ending     chan struct{}
+	endingOnce sync.Once
+}
+
+// newClient creates a Client with a queue of the given size.
+func newClient(w http.ResponseWriter, size int) *Client {
+	if size < 1 {
+		size = 1
+	}
+	return &Client{
+		w:      w,
+		rc:     http.NewResponseController(w),
+		queue:  make(chan history.Entry, size),
+		done:   make(chan struct{}),
+		ending: make(chan struct{}),
+	}
+}
+
+// send writes a history entry as an SSE event to the client.
+func (c *Client) send(entry history.Entry) error {
+	_, err := fmt.Fprintf(c.w, "id: %s\nevent: %s\ndata: %s\n\n", entry.EventID(), entry.Event, entry.Data)
+	return err
+}
+
+// write sends the given entries followed by a flush, within the timeout.
+func (c *Client) write(timeout time.Duration, entries ...history.Entry) error {
+	if timeout > 0 {
+		err := c.rc.SetWriteDeadline(time.Now().Add(timeout))
+		if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+	}
+	for _, entry := range entries {
+		if err := c.send(entry); err != nil {
+			return err
+		}
+	}
+	return c.rc.Flush()
+}
+
+// ping writes a heartbeat comment to the client, within the timeout.
+func (c *Client) ping(timeout time.Duration) error {
+	if timeout > 0 {
+		err := c.rc.SetWriteDeadline(time.Now().Add(timeout))
+		if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+	}
+	if _, err := fmt.Fprint(c.w, ": ping\n\n"); err != nil {
+		return err
+	}
+	return c.rc.Flush()
+}
+
+// enqueue adds the entry to the client queue without blocking, applying
+// the queue policy when the queue is full. It returns false if the client
+// must be disconnected. The shutdown event bypasses the queue, so it's
+// never dropped.
+func (c *Client) enqueue(entry history.Entry, queue Queue) bool {
+	if entry.Event == EventShutdown {
+		c.endingOnce.Do(func() {
+			c.last = entry
+			close(c.ending)
+		})
+		return true
+	}
+	select {
+	case c.queue <- entry:
+		return true
+	default:
+	}
+	switch queue.Policy {
+	case PolicyDisconnect:
+		c.close()
+		queue.Stats.Disconnected.Add(1)
+		return false
+	case PolicyDropOldest:
+		select {
+		case <-c.queue:
+		default:
+		}
+		select {
+		case c.queue <- entry:
+			c.drop(queue)
+			return true
+		default:
+		}
+	}
+	c.drop(queue)
+	return true
+}
+
+// drop accounts for a discarded event.
+func (c *Client) drop(queue Queue) {
+	c.dropped.Add(1)
+	queue.Stats.Dropped.Add(1)
+}
+
+// pending returns the queued entries followed by the shutdown event.
+func (c *Client) pending() []history.Entry {
+	var entries []history.Entry
+	for {
+		select {
+		case entry := <-c.queue:
+			entries = append(entries, entry)
+		default:
+			return append(entries, c.last)
+		}
+	}
+}
+
+// close signals the client writer to stop.
+func (c *Client) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+// EventType returns the event type for a JSON encoded dump message.
+// It defaults to EventMessage for unknown actions.
+func EventType(msg string) string {
+	var action struct {
+		Action string `json:"action"`
+	}
+	if err := json.Unmarshal([]byte(msg), &action); err != nil || !eventTypes[action.Action] {
+		return EventMessage
+	}
+	return action.Action
 }
 
 // StartDispatcher initializes the SSE message dispatcher that broadcasts
-// messages to all connected clients.
-func StartDispatcher(messages chan string, clients map[*Client]bool, clientsMu *sync.Mutex, symmetricKey []byte) {
+// messages to all connected clients and appends them to the history.
+// Messages are handed to each client queue, so a slow client never blocks
+// the delivery to the others. Messages are encrypted with the current key
+// held by symmetricKey, when any.
+func StartDispatcher(messages chan string, clients map[*Client]bool, clientsMu *sync.Mutex, symmetricKey *cipher.Key, store *history.Store, queue Queue) {
 	go func() {
 		for msg := range messages {
-			if symmetricKey != nil {
+			event := EventType(msg)
+			if symmetricKey := symmetricKey.Load(); symmetricKey != nil {
 				msg = cipher.Encrypt(symmetricKey, msg)
+				metrics.EncryptionOperations.Inc()
 			}
 			clientsMu.Lock()
+			if event == EventClear {
+				store.Clear()
+			}
+			entry := store.Append(event, msg)
 			for client := range clients {
-
-				fmt.Fprintf(client.w, "data: %s\n\n", msg)
-				client.flusher.Flush()
+				if !client.enqueue(entry, queue) {
+					delete(clients, client)
+				}
 			}
 			clientsMu.Unlock()
 		}
@@ -46,23 +271,70 @@ func StartDispatcher(messages chan string, clients map[*Client]bool, clientsMu *
 }
 
 // Handle manages SSE connections, setting up appropriate headers and
-// maintaining the connection until the client disconnects.
-func Handle(messages chan string, logger cli.Logger, clients map[*Client]bool, clientsMu *sync.Mutex) http.HandlerFunc {
+// writing the queued events until the client disconnects.
+// On connect it replays the history entries the client hasn't seen yet,
+// as told by the `Last-Event-ID` header. Heartbeat pings keep idle streams
+// alive and clients failing to write are evicted. The stream ends after
+// sending the shutdown event.
+func Handle(messages chan string, logger cli.Logger, clients map[*Client]bool, clientsMu *sync.Mutex, store *history.Store, queue Queue, heartbeat Heartbeat) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/event-stream")
 		w.Header().Set("Connection", "keep-alive")
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		client := &Client{w, w.(http.Flusher)}
+		client := newClient(w, queue.Size)
 		clientsMu.Lock()
+		entries := store.SinceEvent(r.Header.Get("Last-Event-ID"))
 		clients[client] = true
 		clientsMu.Unlock()
-		logger.Printf("Connected %s", r.RemoteAddr)
+		start := time.Now()
+		cli.Info(logger, "Connected", "remote_addr", r.RemoteAddr, "route", r.Pattern, "replayed", len(entries))
 		defer func() {
 			clientsMu.Lock()
 			delete(clients, client)
 			clientsMu.Unlock()
-			logger.Printf("Disconnected %s", r.RemoteAddr)
+			cli.Info(logger, "Disconnected",
+				"remote_addr", r.RemoteAddr,
+				"route", r.Pattern,
+				"dropped", client.dropped.Load(),
+				"latency", time.Since(start))
 		}()
-		<-r.Context().Done()
+		fmt.Fprintf(w, "retry: %d\n\n", retryMilliseconds)
+		if err := client.write(heartbeat.WriteTimeout, entries...); err != nil {
+			cli.Warn(logger, "Evicted", "remote_addr", r.RemoteAddr, "route", r.Pattern, "error", err)
+			return
+		}
+		// Clients connecting after the shutdown get it replayed
+		if len(entries) > 0 && entries[len(entries)-1].Event == EventShutdown {
+			return
+		}
+		var pings <-chan time.Time
+		if heartbeat.Interval > 0 {
+			ticker := time.NewTicker(heartbeat.Interval)
+			defer ticker.Stop()
+			pings = ticker.C
+		}
+		for {
+			var err error
+			select {
+			case entry := <-client.queue:
+				err = client.write(heartbeat.WriteTimeout, entry)
+			case <-client.ending:
+				if err := client.write(heartbeat.WriteTimeout, client.pending()...); err != nil {
+					cli.Warn(logger, "Evicted", "remote_addr", r.RemoteAddr, "route", r.Pattern, "error", err)
+				}
+				return
+			case <-pings:
+				err = client.ping(heartbeat.WriteTimeout)
+			case <-client.done:
+				cli.Warn(logger, "Evicted", "remote_addr", r.RemoteAddr, "route", r.Pattern, "error", "queue full")
+				return
+			case <-r.Context().Done():
+				return
+			}
+			if err != nil {
+				cli.Warn(logger, "Evicted", "remote_addr", r.RemoteAddr, "route", r.Pattern, "error", err)
+				return
+			}
+		}
 	}
 }
@@ -11,10 +11,17 @@ package sse
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/xrdebug/xrdebug/internal/history"
 )
 
 type mockLogger struct {
@@ -22,7 +29,7 @@ type mockLogger struct {
 }
 
 func (m *mockLogger) Printf(format string, v ...interface{}) {
-	m.messages = append(m.messages, format)
+	m.messages = append(m.messages, fmt.Sprintf(format, v...))
 }
 
 func TestStartDispatcher(t *testing.T) {
@@ -30,19 +37,133 @@ func TestStartDispatcher(t *testing.T) {
 	clients := make(map[*Client]bool)
 	clientsMu := &sync.Mutex{}
 	w := httptest.NewRecorder()
-	client := &Client{w: w, flusher: w}
+	client := newClient(w, 10)
 	clientsMu.Lock()
 	clients[client] = true
 	clientsMu.Unlock()
-	StartDispatcher(messages, clients, clientsMu, nil)
-	testMessage := "test message"
+	store := history.New(10, 0)
+	StartDispatcher(messages, clients, clientsMu, nil, store, testQueue(10, PolicyDropOldest))
+	testMessage := `{"action":"pause","id":"123"}`
 	messages <- testMessage
-	time.Sleep(100 * time.Millisecond)
+	select {
+	case entry := <-client.queue:
+		client.send(entry)
+	case <-time.After(time.Second):
+		t.Fatal("Expected entry to be queued")
+	}
 	response := w.Body.String()
-	expected := "data: " + testMessage + "\n\n"
+	expected := "id: " + store.Epoch() + "-1\nevent: pause\ndata: " + testMessage + "\n\n"
 	if response != expected {
 		t.Errorf("Expected response %q, got %q", expected, response)
 	}
+	if store.Len() != 1 {
+		t.Errorf("Expected 1 history entry, got %d", store.Len())
+	}
+}
+
+func TestClientEnqueue(t *testing.T) {
+	tests := []struct {
+		policy       Policy
+		expectedIDs  []uint64
+		dropped      uint64
+		disconnected uint64
+	}{
+		{PolicyDropOldest, []uint64{2, 3}, 1, 0},
+		{PolicyDropNewest, []uint64{1, 2}, 1, 0},
+		{PolicyDisconnect, []uint64{1, 2}, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			queue := testQueue(2, tt.policy)
+			client := newClient(httptest.NewRecorder(), queue.Size)
+			for id := uint64(1); id <= 3; id++ {
+				keep := client.enqueue(history.Entry{ID: id}, queue)
+				if tt.policy == PolicyDisconnect && id == 3 && keep {
+					t.Error("Expected client to be disconnected")
+				}
+			}
+			close(client.queue)
+			var ids []uint64
+			for entry := range client.queue {
+				ids = append(ids, entry.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.expectedIDs) {
+				t.Errorf("Expected queued IDs %v, got %v", tt.expectedIDs, ids)
+			}
+			if got := queue.Stats.Dropped.Load(); got != tt.dropped {
+				t.Errorf("Expected %d dropped, got %d", tt.dropped, got)
+			}
+			if got := queue.Stats.Disconnected.Load(); got != tt.disconnected {
+				t.Errorf("Expected %d disconnected, got %d", tt.disconnected, got)
+			}
+		})
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	for _, policy := range Policies {
+		if got, err := ParsePolicy(string(policy)); err != nil || got != policy {
+			t.Errorf("ParsePolicy(%q) = %q, %v", policy, got, err)
+		}
+	}
+	if _, err := ParsePolicy("block"); err == nil {
+		t.Error("Expected error for unsupported policy")
+	}
+}
+
+// testQueue returns a Queue with its own Stats
+func testQueue(size int, policy Policy) Queue {
+	return Queue{Size: size, Policy: policy, Stats: &Stats{}}
+}
+
+func TestHandleReplay(t *testing.T) {
+	tests := []struct {
+		name        string
+		lastEventID string
+		expected    string
+	}{
+		{
+			name:     "new client",
+			expected: "retry: 3000\n\nid: {epoch}-1\nevent: message\ndata: a\n\nid: {epoch}-2\nevent: message\ndata: b\n\n",
+		},
+		{
+			name:        "reconnecting client",
+			lastEventID: "{epoch}-1",
+			expected:    "retry: 3000\n\nid: {epoch}-2\nevent: message\ndata: b\n\n",
+		},
+		{
+			name:        "up to date client",
+			lastEventID: "{epoch}-2",
+			expected:    "retry: 3000\n\n",
+		},
+		{
+			name:        "reconnecting after a restart",
+			lastEventID: "restarted-5",
+			expected:    "retry: 3000\n\nid: {epoch}-1\nevent: message\ndata: a\n\nid: {epoch}-2\nevent: message\ndata: b\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clients := make(map[*Client]bool)
+			clientsMu := &sync.Mutex{}
+			store := history.New(10, 0)
+			store.Append("message", "a")
+			store.Append("message", "b")
+			handler := Handle(nil, &mockLogger{}, clients, clientsMu, store, testQueue(10, PolicyDropOldest), Heartbeat{})
+			ctx, cancel := context.WithCancel(context.Background())
+			req := httptest.NewRequest("GET", "/stream", nil).WithContext(ctx)
+			if tt.lastEventID != "" {
+				req.Header.Set("Last-Event-ID", strings.ReplaceAll(tt.lastEventID, "{epoch}", store.Epoch()))
+			}
+			w := httptest.NewRecorder()
+			cancel()
+			handler.ServeHTTP(w, req)
+			expected := strings.ReplaceAll(tt.expected, "{epoch}", store.Epoch())
+			if got := w.Body.String(); got != expected {
+				t.Errorf("Expected response %q, got %q", expected, got)
+			}
+		})
+	}
 }
 
 func TestHandleDisconnection(t *testing.T) {
@@ -50,7 +171,7 @@ func TestHandleDisconnection(t *testing.T) {
 	clients := make(map[*Client]bool)
 	clientsMu := &sync.Mutex{}
 	logger := &mockLogger{}
-	handler := Handle(messages, logger, clients, clientsMu)
+	handler := Handle(messages, logger, clients, clientsMu, history.New(10, 0), testQueue(10, PolicyDropOldest), Heartbeat{})
 	ctx, cancel := context.WithCancel(context.Background())
 	req := httptest.NewRequest("GET", "/sse", nil).WithContext(ctx)
 	w := httptest.NewRecorder()
@@ -73,3 +194,161 @@ func TestHandleDisconnection(t *testing.T) {
 		t.Errorf("Expected 0 clients after disconnection, got %d", finalClients)
 	}
 }
+
+func TestEventType(t *testing.T) {
+	tests := []struct {
+		msg      string
+		expected string
+	}{
+		{`{"action":"message"}`, EventMessage},
+		{`{"action":"pause"}`, EventPause},
+		{`{"action":"pause-stop"}`, EventPauseStop},
+		{`{"action":"pause-continue"}`, EventPauseContinue},
+		{`{"action":"clear"}`, EventClear},
+		{`{"action":"shutdown"}`, EventShutdown},
+		{`{"action":"unknown"}`, EventMessage},
+		{`not json`, EventMessage},
+	}
+	for _, tt := range tests {
+		if got := EventType(tt.msg); got != tt.expected {
+			t.Errorf("EventType(%q) = %q, want %q", tt.msg, got, tt.expected)
+		}
+	}
+}
+
+func TestHandleHeartbeat(t *testing.T) {
+	clients := make(map[*Client]bool)
+	clientsMu := &sync.Mutex{}
+	handler := Handle(nil, &mockLogger{}, clients, clientsMu, history.New(10, 0),
+		testQueue(10, PolicyDropOldest), Heartbeat{Interval: 10 * time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest("GET", "/stream", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), ": ping\n\n") {
+		t.Errorf("Expected ping comment, got %q", w.Body.String())
+	}
+}
+
+func TestHandleEviction(t *testing.T) {
+	clients := make(map[*Client]bool)
+	clientsMu := &sync.Mutex{}
+	logger := &mockLogger{}
+	handler := Handle(nil, logger, clients, clientsMu, history.New(10, 0),
+		testQueue(10, PolicyDropOldest), Heartbeat{Interval: 10 * time.Millisecond})
+	req := httptest.NewRequest("GET", "/stream", nil)
+	w := &failingWriter{httptest.NewRecorder()}
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(w, req)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected client to be evicted")
+	}
+	if len(clients) != 0 {
+		t.Errorf("Expected 0 clients after eviction, got %d", len(clients))
+	}
+	evicted := func(msg string) bool { return strings.HasPrefix(msg, "Evicted") }
+	if !slices.ContainsFunc(logger.messages, evicted) {
+		t.Errorf("Expected eviction log, got %v", logger.messages)
+	}
+}
+
+// failingWriter is a ResponseWriter whose writes always fail
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w *failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestHandleShutdown(t *testing.T) {
+	messages := make(chan string)
+	clients := make(map[*Client]bool)
+	clientsMu := &sync.Mutex{}
+	store := history.New(10, 0)
+	queue := testQueue(10, PolicyDropOldest)
+	StartDispatcher(messages, clients, clientsMu, nil, store, queue)
+	handler := Handle(messages, &mockLogger{}, clients, clientsMu, store, queue, Heartbeat{})
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/stream", nil))
+		close(done)
+	}()
+	deadline := time.Now().Add(time.Second)
+	for {
+		clientsMu.Lock()
+		connected := len(clients)
+		clientsMu.Unlock()
+		if connected == 1 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	messages <- `{"action":"shutdown"}`
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the stream to end after the shutdown event")
+	}
+	if !strings.Contains(w.Body.String(), "event: shutdown\n") {
+		t.Errorf("Expected shutdown event, got %q", w.Body.String())
+	}
+}
+
+func TestClientEnqueueShutdown(t *testing.T) {
+	for _, policy := range Policies {
+		t.Run(string(policy), func(t *testing.T) {
+			queue := testQueue(1, policy)
+			client := newClient(httptest.NewRecorder(), queue.Size)
+			client.enqueue(history.Entry{ID: 1, Event: EventMessage}, queue)
+			if !client.enqueue(history.Entry{ID: 2, Event: EventShutdown}, queue) {
+				t.Fatal("Expected client to be kept for the shutdown event")
+			}
+			select {
+			case <-client.ending:
+			default:
+				t.Fatal("Expected the shutdown event to bypass the full queue")
+			}
+			var ids []uint64
+			for _, entry := range client.pending() {
+				ids = append(ids, entry.ID)
+			}
+			if !reflect.DeepEqual(ids, []uint64{1, 2}) {
+				t.Errorf("Expected pending IDs [1 2], got %v", ids)
+			}
+			if got := queue.Stats.Dropped.Load() + queue.Stats.Disconnected.Load(); got != 0 {
+				t.Errorf("Expected no dropped events nor disconnections, got %d", got)
+			}
+		})
+	}
+}
+
+func TestHandleReplayShutdown(t *testing.T) {
+	clients := make(map[*Client]bool)
+	clientsMu := &sync.Mutex{}
+	store := history.New(10, 0)
+	store.Append(EventMessage, "a")
+	store.Append(EventShutdown, "b")
+	handler := Handle(nil, &mockLogger{}, clients, clientsMu, store, testQueue(10, PolicyDropOldest), Heartbeat{})
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/stream", nil))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the stream to end after replaying the shutdown event")
+	}
+	if !strings.HasSuffix(w.Body.String(), "event: shutdown\ndata: b\n\n") {
+		t.Errorf("Expected shutdown event, got %q", w.Body.String())
+	}
+}
@@ -10,14 +10,42 @@
 package dump
 
 import (
+	"fmt"
+	"net/url"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
 )
 
-// scriptTagPattern is a compiled regex for matching and removing script tags
-const scriptTagPattern = `(?i)<script.*?>.*?</script>`
+// fileLineRegex matches the accepted values for the file line
+var fileLineRegex = regexp.MustCompile(`^[0-9]+$`)
 
-var scriptTagRegex = regexp.MustCompile(scriptTagPattern)
+// FieldLimits defines the maximum length (in bytes) for the dump fields.
+// The body is only bounded by the request size limit.
+var FieldLimits = map[string]int{
+	"file_path": 4096,
+	"file_line": 10,
+	"emote":     64,
+	"topic":     128,
+	"id":        128,
+}
+
+// ValidationError reports the fields that failed validation and why
+type ValidationError struct {
+	// Fields maps each invalid field to its reason
+	Fields map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for field, reason := range e.Fields {
+		fields = append(fields, field+" "+reason)
+	}
+	sort.Strings(fields)
+	return "invalid fields: " + strings.Join(fields, ", ")
+}
 
 // Dump represents a debug message with associated metadata and file information
 type Dump struct {
@@ -41,14 +69,37 @@ type Dump struct {
 	ID string `json:"id"`
 }
 
-// StripScriptTags removes any script tags from the input string for security
-func StripScriptTags(input string) string {
-	return scriptTagRegex.ReplaceAllString(input, "")
+// Validate checks the dump fields against the field limits and formats.
+// It returns a *ValidationError listing every invalid field, or nil.
+func Validate(values url.Values, required ...string) error {
+	fields := make(map[string]string)
+	for _, field := range required {
+		if values.Get(field) == "" {
+			fields[field] = "is required"
+		}
+	}
+	for field, limit := range FieldLimits {
+		if len(values.Get(field)) > limit {
+			fields[field] = fmt.Sprintf("exceeds %d bytes", limit)
+		}
+	}
+	for field := range values {
+		if !utf8.ValidString(values.Get(field)) {
+			fields[field] = "must be valid UTF-8"
+		}
+	}
+	if fileLine := values.Get("file_line"); fileLine != "" && !fileLineRegex.MatchString(fileLine) {
+		fields["file_line"] = "must be an integer"
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
 }
 
 // New creates a new Dump instance with the provided parameters
 func New(action, body, filePath, fileLine, emote, topic, id string) *Dump {
-	body = StripScriptTags(body)
+	body = Sanitize(body)
 	fileDisplay := filepath.Clean(filePath)
 	fileDisplayShort := filepath.Base(fileDisplay)
 	if fileLine != "" {
@@ -10,47 +10,14 @@
 package dump
 
 import (
+	"errors"
+	"net/url"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
-func TestStripScriptTags(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected string
-	}{
-		{
-			name:     "no script tags",
-			input:    "Hello, world!",
-			expected: "Hello, world!",
-		},
-		{
-			name:     "single script tag",
-			input:    "Before<script>alert('test')</script>After",
-			expected: "BeforeAfter",
-		},
-		{
-			name:     "multiple script tags",
-			input:    "Start<script>alert(1)</script>Middle<script>console.log('test')</script>End",
-			expected: "StartMiddleEnd",
-		},
-		{
-			name:     "case insensitive",
-			input:    "<SCRIPT>test</SCRIPT><script>test</script><ScRiPt>test</ScRiPt>",
-			expected: "",
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := StripScriptTags(tt.input)
-			if result != tt.expected {
-				t.Errorf("expected %q, got %q", tt.expected, result)
-			}
-		})
-	}
-}
-
 func TestNew(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -115,3 +82,71 @@ func TestNew(t *testing.T) {
 		})
 	}
 }
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   url.Values
+		required []string
+		expected map[string]string
+	}{
+		{
+			name: "valid",
+			values: url.Values{
+				"body":      {"test"},
+				"file_line": {"42"},
+				"topic":     {"testing"},
+			},
+		},
+		{
+			name:     "non numeric file line",
+			values:   url.Values{"file_line": {"42a"}},
+			expected: map[string]string{"file_line": "must be an integer"},
+		},
+		{
+			name:     "negative file line",
+			values:   url.Values{"file_line": {"-1"}},
+			expected: map[string]string{"file_line": "must be an integer"},
+		},
+		{
+			name: "too long",
+			values: url.Values{
+				"topic": {strings.Repeat("a", 129)},
+				"emote": {strings.Repeat("a", 65)},
+			},
+			expected: map[string]string{
+				"topic": "exceeds 128 bytes",
+				"emote": "exceeds 64 bytes",
+			},
+		},
+		{
+			name:     "invalid utf-8",
+			values:   url.Values{"body": {"\xff"}},
+			expected: map[string]string{"body": "must be valid UTF-8"},
+		},
+		{
+			name:     "required",
+			values:   url.Values{"body": {"test"}},
+			required: []string{"id"},
+			expected: map[string]string{"id": "is required"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.values, tt.required...)
+			if tt.expected == nil {
+				if err != nil {
+					t.Errorf("unexpected error %v", err)
+				}
+				return
+			}
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("expected ValidationError, got %v", err)
+			}
+			if !reflect.DeepEqual(validationErr.Fields, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, validationErr.Fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,343 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package dump
+
+import (
+	"html"
+	"strings"
+)
+
+// allowedElements lists the elements kept by Sanitize, which covers the
+// markup produced by var-dump libraries.
+var allowedElements = map[string]bool{
+	"a": true, "abbr": true, "b": true, "blockquote": true, "br": true,
+	"caption": true, "cite": true, "code": true, "col": true, "colgroup": true,
+	"dd": true, "del": true, "details": true, "div": true, "dl": true,
+	"dt": true, "em": true, "h1": true, "h2": true, "h3": true, "h4": true,
+	"h5": true, "h6": true, "hr": true, "i": true, "ins": true, "kbd": true,
+	"li": true, "mark": true, "ol": true, "p": true, "pre": true, "q": true,
+	"s": true, "samp": true, "small": true, "span": true, "strong": true,
+	"sub": true, "summary": true, "sup": true, "table": true, "tbody": true,
+	"td": true, "tfoot": true, "th": true, "thead": true, "time": true,
+	"tr": true, "u": true, "ul": true, "var": true, "wbr": true,
+}
+
+// voidElements lists the elements without content nor end tag.
+var voidElements = map[string]bool{
+	"base": true, "br": true, "col": true, "embed": true, "frame": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"param": true, "source": true, "wbr": true,
+}
+
+// droppedElements lists the elements removed along with their content.
+var droppedElements = map[string]bool{
+	"applet": true, "audio": true, "base": true, "button": true, "canvas": true,
+	"embed": true, "form": true, "frame": true, "frameset": true,
+	"iframe": true, "img": true, "input": true, "link": true, "math": true,
+	"meta": true, "noembed": true, "noframes": true, "noscript": true,
+	"object": true, "option": true, "param": true, "picture": true,
+	"plaintext": true, "script": true, "select": true, "source": true,
+	"style": true, "svg": true, "template": true, "textarea": true,
+	"title": true, "video": true, "xmp": true,
+}
+
+// rawTextElements lists the dropped elements whose content is not markup,
+// these are skipped up to their end tag.
+var rawTextElements = map[string]bool{
+	"iframe": true, "noembed": true, "noframes": true, "noscript": true,
+	"plaintext": true, "script": true, "style": true, "textarea": true,
+	"title": true, "xmp": true,
+}
+
+// allowedAttributes lists the attributes kept for any allowed element.
+var allowedAttributes = map[string]bool{
+	"class": true, "colspan": true, "datetime": true, "dir": true,
+	"lang": true, "open": true, "rowspan": true, "style": true, "title": true,
+}
+
+// unsafeStyles lists the (lowercase) tokens rejecting a style attribute.
+var unsafeStyles = []string{"url(", "expression", "javascript:", "@import", "behavior", "binding", "\\", "/*"}
+
+// safeSchemes lists the URL schemes kept in links.
+var safeSchemes = []string{"http:", "https:", "mailto:"}
+
+// attribute is a parsed tag attribute with its decoded value.
+type attribute struct {
+	name  string
+	value string
+}
+
+// sanitizer holds the state for a single Sanitize call.
+type sanitizer struct {
+	input string
+	pos   int
+	out   strings.Builder
+}
+
+// Sanitize removes active content from HTML using an allowlist of elements
+// and attributes, keeping the markup produced by var-dump libraries.
+// Scripts, styles, embedded content, event handlers and unsafe URLs are
+// removed, unknown elements are unwrapped and text is escaped.
+func Sanitize(input string) string {
+	s := &sanitizer{input: input}
+	s.out.Grow(len(input))
+	for s.pos < len(s.input) {
+		i := strings.IndexByte(s.input[s.pos:], '<')
+		if i < 0 {
+			s.text(s.input[s.pos:])
+			break
+		}
+		s.text(s.input[s.pos : s.pos+i])
+		s.pos += i
+		s.markup()
+	}
+	return s.out.String()
+}
+
+// text writes text content, escaping stray angle brackets.
+func (s *sanitizer) text(text string) {
+	text = strings.ReplaceAll(text, "<", "&lt;")
+	s.out.WriteString(strings.ReplaceAll(text, ">", "&gt;"))
+}
+
+// markup handles the markup starting at the current `<`.
+func (s *sanitizer) markup() {
+	rest := s.input[s.pos:]
+	switch {
+	case strings.HasPrefix(rest, "<!--"):
+		s.skipPast("-->", 4)
+	case strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?"):
+		s.skipPast(">", 2)
+	case len(rest) > 2 && rest[1] == '/' && isLetter(rest[2]):
+		s.pos += 2
+		name, _, ok := s.tag()
+		if ok && allowedElements[name] && !voidElements[name] {
+			s.out.WriteString("</" + name + ">")
+		}
+	case len(rest) > 1 && isLetter(rest[1]):
+		s.pos++
+		name, attributes, ok := s.tag()
+		if !ok {
+			return
+		}
+		switch {
+		case rawTextElements[name]:
+			s.skipRawText(name)
+		case droppedElements[name] && !voidElements[name]:
+			s.skipElement(name)
+		case allowedElements[name]:
+			s.startTag(name, attributes)
+		}
+	default:
+		s.out.WriteString("&lt;")
+		s.pos++
+	}
+}
+
+// tag parses a tag name and its attributes up to the closing `>`.
+// It returns false when the input ends before the tag is closed.
+func (s *sanitizer) tag() (string, []attribute, bool) {
+	start := s.pos
+	for s.pos < len(s.input) && !isSpace(s.input[s.pos]) && s.input[s.pos] != '/' && s.input[s.pos] != '>' {
+		s.pos++
+	}
+	name := strings.ToLower(s.input[start:s.pos])
+	var attributes []attribute
+	for s.pos < len(s.input) {
+		c := s.input[s.pos]
+		switch {
+		case c == '>':
+			s.pos++
+			return name, attributes, true
+		case isSpace(c) || c == '/':
+			s.pos++
+		default:
+			attributes = append(attributes, s.attribute())
+		}
+	}
+	return name, attributes, false
+}
+
+// attribute parses a single attribute, decoding its value.
+func (s *sanitizer) attribute() attribute {
+	start := s.pos
+	for s.pos < len(s.input) && !isSpace(s.input[s.pos]) && !strings.ContainsRune("/>=", rune(s.input[s.pos])) {
+		s.pos++
+	}
+	if s.pos == start {
+		s.pos++
+	}
+	attr := attribute{name: strings.ToLower(s.input[start:s.pos])}
+	for s.pos < len(s.input) && isSpace(s.input[s.pos]) {
+		s.pos++
+	}
+	if s.pos >= len(s.input) || s.input[s.pos] != '=' {
+		return attr
+	}
+	s.pos++
+	for s.pos < len(s.input) && isSpace(s.input[s.pos]) {
+		s.pos++
+	}
+	if s.pos >= len(s.input) {
+		return attr
+	}
+	if quote := s.input[s.pos]; quote == '"' || quote == '\'' {
+		end := strings.IndexByte(s.input[s.pos+1:], quote)
+		if end < 0 {
+			s.pos = len(s.input)
+			return attr
+		}
+		attr.value = html.UnescapeString(s.input[s.pos+1 : s.pos+1+end])
+		s.pos += end + 2
+		return attr
+	}
+	start = s.pos
+	for s.pos < len(s.input) && !isSpace(s.input[s.pos]) && s.input[s.pos] != '>' {
+		s.pos++
+	}
+	attr.value = html.UnescapeString(s.input[start:s.pos])
+	return attr
+}
+
+// startTag writes an allowed start tag with its safe attributes.
+func (s *sanitizer) startTag(name string, attributes []attribute) {
+	s.out.WriteString("<" + name)
+	seen := make(map[string]bool)
+	for _, attr := range attributes {
+		if seen[attr.name] || !safeAttribute(name, attr) {
+			continue
+		}
+		seen[attr.name] = true
+		s.out.WriteString(" " + attr.name + `="` + html.EscapeString(attr.value) + `"`)
+	}
+	s.out.WriteString(">")
+}
+
+// skipRawText skips the content of a raw text element up to its end tag,
+// or to the end of the input when the element is not closed.
+func (s *sanitizer) skipRawText(name string) {
+	end := indexEndTag(s.input[s.pos:], name)
+	if end < 0 {
+		s.pos = len(s.input)
+		return
+	}
+	s.pos += end + 2
+	s.tag()
+}
+
+// skipElement skips a dropped element and all its nested content.
+func (s *sanitizer) skipElement(name string) {
+	depth := 1
+	for depth > 0 && s.pos < len(s.input) {
+		i := strings.IndexByte(s.input[s.pos:], '<')
+		if i < 0 {
+			s.pos = len(s.input)
+			return
+		}
+		s.pos += i
+		rest := s.input[s.pos:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			s.skipPast("-->", 4)
+		case len(rest) > 2 && rest[1] == '/' && isLetter(rest[2]):
+			s.pos += 2
+			if tag, _, _ := s.tag(); tag == name {
+				depth--
+			}
+		case len(rest) > 1 && isLetter(rest[1]):
+			s.pos++
+			tag, _, _ := s.tag()
+			switch {
+			case rawTextElements[tag]:
+				s.skipRawText(tag)
+			case tag == name && !strings.HasSuffix(s.input[:s.pos], "/>"):
+				depth++
+			}
+		default:
+			s.pos++
+		}
+	}
+}
+
+// skipPast moves past the next occurrence of marker, searching after the
+// offset, or to the end of the input.
+func (s *sanitizer) skipPast(marker string, offset int) {
+	end := strings.Index(s.input[s.pos+offset:], marker)
+	if end < 0 {
+		s.pos = len(s.input)
+		return
+	}
+	s.pos += offset + end + len(marker)
+}
+
+// safeAttribute reports whether the attribute can be kept for the element.
+func safeAttribute(element string, attr attribute) bool {
+	switch {
+	case attr.name == "href":
+		return element == "a" && safeURL(attr.value)
+	case attr.name == "style":
+		style := strings.ToLower(attr.value)
+		for _, token := range unsafeStyles {
+			if strings.Contains(style, token) {
+				return false
+			}
+		}
+		return true
+	}
+	return allowedAttributes[attr.name]
+}
+
+// safeURL reports whether the URL is relative, a fragment or uses a safe scheme.
+func safeURL(value string) bool {
+	var normalized strings.Builder
+	for _, r := range value {
+		if r > ' ' && r != 0x7f {
+			normalized.WriteRune(r)
+		}
+	}
+	url := strings.ToLower(normalized.String())
+	colon := strings.IndexByte(url, ':')
+	if colon < 0 || strings.ContainsAny(url[:colon], "/?#") {
+		return true
+	}
+	for _, scheme := range safeSchemes {
+		if strings.HasPrefix(url, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// indexEndTag returns the index of the first end tag for the given ASCII
+// tag name, matched case-insensitively. Only the ASCII bytes after `</` are
+// compared, so non-ASCII text before the end tag doesn't shift the index.
+func indexEndTag(s, name string) int {
+	offset := 0
+	for {
+		i := strings.Index(s[offset:], "</")
+		if i < 0 {
+			return -1
+		}
+		i += offset
+		start := i + 2
+		if start+len(name) <= len(s) && strings.EqualFold(s[start:start+len(name)], name) {
+			return i
+		}
+		offset = start
+	}
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
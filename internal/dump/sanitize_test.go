@@ -0,0 +1,163 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package dump
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "no script tags",
+			input:    "Hello, world!",
+			expected: "Hello, world!",
+		},
+		{
+			name:     "single script tag",
+			input:    "Before<script>alert('test')</script>After",
+			expected: "BeforeAfter",
+		},
+		{
+			name:     "multiple script tags",
+			input:    "Start<script>alert(1)</script>Middle<script>console.log('test')</script>End",
+			expected: "StartMiddleEnd",
+		},
+		{
+			name:     "case insensitive",
+			input:    "<SCRIPT>test</SCRIPT><script>test</script><ScRiPt>test</ScRiPt>",
+			expected: "",
+		},
+		{
+			name:     "var-dump markup",
+			input:    `<pre class="chv-dump"><span class="chv-dump-type">string</span> <span class="chv-dump-value" title="len 4">test</span></pre>`,
+			expected: `<pre class="chv-dump"><span class="chv-dump-type">string</span> <span class="chv-dump-value" title="len 4">test</span></pre>`,
+		},
+		{
+			name:     "details and summary",
+			input:    `<details open><summary>array</summary><div>0 =&gt; 1<br/></div></details>`,
+			expected: `<details open=""><summary>array</summary><div>0 =&gt; 1<br></div></details>`,
+		},
+		{
+			name:     "unquoted attributes",
+			input:    `<span class=chv-dump-int>1</span>`,
+			expected: `<span class="chv-dump-int">1</span>`,
+		},
+		{
+			name:     "escaped attribute value",
+			input:    `<span title='a"b<c'>x</span>`,
+			expected: `<span title="a&#34;b&lt;c">x</span>`,
+		},
+		{
+			name:     "safe style",
+			input:    `<span style="color:#ff0000">x</span>`,
+			expected: `<span style="color:#ff0000">x</span>`,
+		},
+		{
+			name:     "safe link",
+			input:    `<a href="https://xrdebug.com">x</a><a href="#ref-1">y</a>`,
+			expected: `<a href="https://xrdebug.com">x</a><a href="#ref-1">y</a>`,
+		},
+		{
+			name:     "stray angle brackets",
+			input:    "1 < 2 > 0 <",
+			expected: "1 &lt; 2 &gt; 0 &lt;",
+		},
+		{
+			name:     "unknown element unwrapped",
+			input:    "<custom-tag>text</custom-tag>",
+			expected: "text",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Sanitize(tt.input)
+			if result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestSanitizeXSS(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"unclosed script", "a<script>alert(1)", "a"},
+		{"script with attributes", `<script src="https://evil.test/x.js"></script>b`, "b"},
+		{"script end tag with spaces", "<script>alert(1)</script >b", "b"},
+		{"nested script split", "<scr<script>ipt>alert(1)</script>", "ipt&gt;alert(1)"},
+		{"img onerror", `<img src=x onerror=alert(1)>b`, "b"},
+		{"event handler", `<span onclick="alert(1)" class="a">x</span>`, `<span class="a">x</span>`},
+		{"event handler uppercase", `<div ONMOUSEOVER=alert(1)>x</div>`, `<div>x</div>`},
+		{"javascript url", `<a href="javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"javascript url mixed case", `<a href="JaVaScRiPt:alert(1)">x</a>`, `<a>x</a>`},
+		{"javascript url entities", `<a href="jav&#x61;script&colon;alert(1)">x</a>`, `<a>x</a>`},
+		{"javascript url whitespace", "<a href=\" java\tscript:alert(1)\">x</a>", `<a>x</a>`},
+		{"data url", `<a href="data:text/html;base64,PHNjcmlwdD4=">x</a>`, `<a>x</a>`},
+		{"vbscript url", `<a href="vbscript:msgbox(1)">x</a>`, `<a>x</a>`},
+		{"href on span", `<span href="https://xrdebug.com">x</span>`, `<span>x</span>`},
+		{"iframe", `<iframe src="javascript:alert(1)"></iframe>b`, "b"},
+		{"iframe srcdoc", `<iframe srcdoc="<script>alert(1)</script>">x</iframe>`, ""},
+		{"svg onload", `<svg onload=alert(1)>`, ""},
+		{"svg nested script", `<svg><g><script>alert(1)</script></g></svg>b`, "b"},
+		{"svg nested svg", `<svg><svg></svg><a xlink:href="javascript:alert(1)">x</a></svg>b`, "b"},
+		{"math mutation", `<math><mtext><table><mglyph><style><img src=x onerror=alert(1)>`, ""},
+		{"noscript mutation", `<noscript><p title="</noscript><img src=x onerror=alert(1)>">`, `"&gt;`},
+		{"object", `<object data="javascript:alert(1)"></object>`, ""},
+		{"embed", `<embed src="javascript:alert(1)">b`, "b"},
+		{"form action", `<form action="javascript:alert(1)"><button>x</button></form>b`, "b"},
+		{"meta refresh", `<meta http-equiv="refresh" content="0;url=javascript:alert(1)">b`, "b"},
+		{"base href", `<base href="https://evil.test/">b`, "b"},
+		{"link stylesheet", `<link rel="stylesheet" href="https://evil.test/x.css">b`, "b"},
+		{"style element", `<style>body{background:url(javascript:alert(1))}</style>b`, "b"},
+		{"style url", `<div style="background:url(javascript:alert(1))">x</div>`, `<div>x</div>`},
+		{"style expression", `<div style="width:expression(alert(1))">x</div>`, `<div>x</div>`},
+		{"style escapes", `<div style="background:\75rl(x)">x</div>`, `<div>x</div>`},
+		{"template", `<template><img src=x onerror=alert(1)></template>b`, "b"},
+		{"comment", `<!-- <script>alert(1)</script> -->b`, "b"},
+		{"conditional comment", `<!--[if IE]><script>alert(1)</script><![endif]-->b`, "b"},
+		{"unclosed comment", `b<!-- <img src=x onerror=alert(1)>`, "b"},
+		{"doctype", `<!DOCTYPE html>b`, "b"},
+		{"processing instruction", `<?xml version="1.0"?>b`, "b"},
+		{"unclosed tag", `b<span onclick="alert(1)"`, "b"},
+		{"unclosed attribute", `b<span title="x><img src=x onerror=alert(1)>`, "b"},
+		{"id and data attributes", `<span id="cipher" data-action="clear">x</span>`, `<span>x</span>`},
+		{"tag with slash separator", `<img/src=x/onerror=alert(1)>`, ""},
+		{"plaintext", `<plaintext><script>alert(1)</script>`, ""},
+		{"script with shrinking case folding", "<script>" + strings.Repeat("ẞ", 40) + "<a href=\"javascript:alert(1)\">x</a></script>b", "b"},
+		{"script with growing case folding", "<script>" + strings.Repeat("Ⱥ", 40) + "</script><b>b</b>", "<b>b</b>"},
+		{"style with dotted capital i", "<style>İİİİ</style><img src=x onerror=alert(1)>b", "b"},
+		{"script with invalid utf-8", "<script>\xff\xfe\xfd</script><b>b</b>", "<b>b</b>"},
+		{"script with non-ascii end tag", "<script>alert(1)</ſcript><img src=x onerror=alert(1)></script>b", "b"},
+		{"textarea with non-ascii text", "<textarea>ünïcödé ẞ</textarea><img src=x onerror=alert(1)>b", "b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Sanitize(tt.input)
+			if result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+			lower := strings.ToLower(result)
+			for _, vector := range []string{"<script", "<img", "<iframe", "<svg", "onerror", "onload", "javascript:"} {
+				if strings.Contains(lower, vector) {
+					t.Errorf("unexpected %q in %q", vector, result)
+				}
+			}
+		})
+	}
+}
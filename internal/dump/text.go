@@ -0,0 +1,66 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package dump
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// blockElements lists the elements rendered on their own lines by Text.
+var blockElements = map[string]bool{
+	"blockquote": true, "caption": true, "dd": true, "details": true,
+	"div": true, "dl": true, "dt": true, "h1": true, "h2": true, "h3": true,
+	"h4": true, "h5": true, "h6": true, "hr": true, "li": true, "ol": true,
+	"p": true, "pre": true, "summary": true, "table": true, "ul": true,
+}
+
+// blankLinesRegex matches runs of blank lines
+var blankLinesRegex = regexp.MustCompile(`\n[ \t]*(\n[ \t]*)+\n`)
+
+// Text converts an HTML body to readable plain text. The body is sanitized
+// first, then tags are removed with block elements and line breaks placed
+// on their own lines, table cells separated by tabs and entities decoded.
+func Text(input string) string {
+	sanitized := Sanitize(input)
+	var out strings.Builder
+	for len(sanitized) > 0 {
+		i := strings.IndexByte(sanitized, '<')
+		if i < 0 {
+			out.WriteString(sanitized)
+			break
+		}
+		out.WriteString(sanitized[:i])
+		end := strings.IndexByte(sanitized[i:], '>')
+		if end < 0 {
+			break
+		}
+		tag := sanitized[i+1 : i+end]
+		sanitized = sanitized[i+end+1:]
+		closing := strings.HasPrefix(tag, "/")
+		name, _, _ := strings.Cut(strings.TrimPrefix(tag, "/"), " ")
+		switch {
+		case name == "br" || (name == "tr" && !closing):
+			out.WriteByte('\n')
+		case (name == "td" || name == "th") && closing:
+			out.WriteByte('\t')
+		case blockElements[name]:
+			out.WriteByte('\n')
+		}
+	}
+	text := html.UnescapeString(out.String())
+	text = blankLinesRegex.ReplaceAllString(text, "\n\n")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
@@ -0,0 +1,73 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package dump
+
+import "testing"
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "plain text",
+			input:    "Hello, world!",
+			expected: "Hello, world!",
+		},
+		{
+			name:     "inline elements",
+			input:    `<span class="type">string</span> <b>"hello"</b>`,
+			expected: `string "hello"`,
+		},
+		{
+			name:     "entities",
+			input:    "a &lt; b &amp;&amp; c &gt; d",
+			expected: "a < b && c > d",
+		},
+		{
+			name:     "line breaks",
+			input:    "one<br>two<BR/>three",
+			expected: "one\ntwo\nthree",
+		},
+		{
+			name:     "block elements",
+			input:    "<div>first</div><div>second</div><p>third</p>",
+			expected: "first\n\nsecond\n\nthird",
+		},
+		{
+			name:     "preformatted dump",
+			input:    "<pre>array(2) {\n  [0]=>\n  int(1)\n}</pre>",
+			expected: "array(2) {\n  [0]=>\n  int(1)\n}",
+		},
+		{
+			name:     "table",
+			input:    "<table><tr><th>key</th><th>value</th></tr><tr><td>a</td><td>1</td></tr></table>",
+			expected: "key\tvalue\na\t1",
+		},
+		{
+			name:     "scripts removed",
+			input:    "before<script>alert(1)</script><style>b{}</style>after",
+			expected: "beforeafter",
+		},
+		{
+			name:     "trailing spaces",
+			input:    "<div>one   </div>\n\n\n\n<div>two</div>",
+			expected: "one\n\ntwo",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Text(tt.input); got != tt.expected {
+				t.Errorf("Text(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+// Package follow reads the server event stream and renders the debug
+// messages for terminals.
+package follow
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/xrdebug/xrdebug/internal/dump"
+)
+
+// ANSI escape sequences used by Render
+const (
+	reset  = "\033[0m"
+	bold   = "\033[1m"
+	dim    = "\033[2m"
+	red    = "\033[31m"
+	green  = "\033[32m"
+	yellow = "\033[33m"
+	cyan   = "\033[36m"
+)
+
+// maxEventSize is the maximum size of a stream line
+const maxEventSize = 16 << 20
+
+// Event is a server-sent event
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+// ReadEvents parses the server-sent events from r, calling fn for each
+// event with data. Comments and retry fields are ignored. It returns when
+// r ends or fn returns an error.
+func ReadEvents(r io.Reader, fn func(Event) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxEventSize)
+	var event Event
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if len(data) > 0 {
+				if event.Event == "" {
+					event.Event = "message"
+				}
+				event.Data = strings.Join(data, "\n")
+				if err := fn(event); err != nil {
+					return err
+				}
+			}
+			event = Event{}
+			data = nil
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			event.ID = value
+		case "event":
+			event.Event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	return scanner.Err()
+}
+
+// Printer renders the debug messages to a terminal
+type Printer struct {
+	w     io.Writer
+	color bool
+}
+
+// NewPrinter creates a Printer writing to w, using ANSI colors when color
+// is true.
+func NewPrinter(w io.Writer, color bool) *Printer {
+	return &Printer{w: w, color: color}
+}
+
+// paint wraps text with the ANSI style when colors are enabled.
+func (p *Printer) paint(style, text string) string {
+	if !p.color || text == "" {
+		return text
+	}
+	return style + text + reset
+}
+
+// Dump renders a debug message with its topic, emote and file followed by
+// its body converted to plain text.
+func (p *Printer) Dump(d dump.Dump) {
+	header := []string{}
+	if d.Action == "pause" {
+		header = append(header, p.paint(bold+yellow, "PAUSE"))
+	}
+	if topic := sanitize(d.Topic, false); topic != "" {
+		header = append(header, p.paint(cyan, "#"+topic))
+	}
+	if emote := sanitize(d.Emote, false); emote != "" {
+		header = append(header, emote)
+	}
+	if d.FilePath != "" {
+		header = append(header, p.paint(dim, sanitize(d.FileDisplay, false)))
+	}
+	if len(header) > 0 {
+		fmt.Fprintln(p.w, strings.Join(header, " "))
+	}
+	if body := sanitize(dump.Text(d.Message), true); body != "" {
+		fmt.Fprintln(p.w, body)
+	}
+	if d.Action == "pause" {
+		fmt.Fprintf(p.w, "%s\n", p.paint(yellow, fmt.Sprintf("Paused %s, enter `c` to continue or `s` to stop", sanitize(d.ID, false))))
+	}
+	fmt.Fprintln(p.w, p.paint(dim, "--"))
+}
+
+// Status renders a status line, such as a pause being continued.
+func (p *Printer) Status(action, text string) {
+	style := dim
+	switch action {
+	case "pause-continue":
+		style = green
+	case "pause-stop", "pause-expired", "shutdown", "error":
+		style = red
+	}
+	fmt.Fprintln(p.w, p.paint(style, sanitize(text, false)))
+}
+
+// sanitize removes the C0 and C1 control characters from the text sent by
+// the server, so it can't move the cursor, clear the screen or retitle the
+// terminal with escape sequences. Newlines and tabs are kept when
+// multiline is true, invalid UTF-8 bytes are replaced.
+func sanitize(text string, multiline bool) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case multiline && (r == '\n' || r == '\t'):
+			return r
+		case r < 0x20, r >= 0x7f && r <= 0x9f:
+			return -1
+		}
+		return r
+	}, strings.ToValidUTF8(text, "\uFFFD"))
+}
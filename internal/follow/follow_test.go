@@ -0,0 +1,126 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package follow
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xrdebug/xrdebug/internal/dump"
+)
+
+func TestReadEvents(t *testing.T) {
+	stream := "retry: 3000\n\n" +
+		": ping\n\n" +
+		"id: 1\nevent: message\ndata: {\"id\":\"a\"}\n\n" +
+		"id: 2\nevent: pause\ndata:line1\ndata: line2\n\n" +
+		"data: unnamed\n\n" +
+		"id: 3\nevent: clear\n"
+	var got []Event
+	err := ReadEvents(strings.NewReader(stream), func(e Event) error {
+		got = append(got, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Event{
+		{ID: "1", Event: "message", Data: `{"id":"a"}`},
+		{ID: "2", Event: "pause", Data: "line1\nline2"},
+		{Event: "message", Data: "unnamed"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestReadEventsStop(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	err := ReadEvents(strings.NewReader("data: a\n\ndata: b\n\n"), func(e Event) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("Expected stop after 1 call, got %v after %d", err, calls)
+	}
+}
+
+func TestPrinterDump(t *testing.T) {
+	var buf bytes.Buffer
+	printer := NewPrinter(&buf, false)
+	printer.Dump(dump.Dump{
+		Action:      "pause",
+		Message:     "<pre>int(1)</pre><script>alert(1)</script>",
+		FilePath:    "/app/index.php",
+		FileDisplay: "/app/index.php:12",
+		Emote:       "🐘",
+		Topic:       "db",
+		ID:          "abc",
+	})
+	expected := "PAUSE #db 🐘 /app/index.php:12\nint(1)\nPaused abc, enter `c` to continue or `s` to stop\n--\n"
+	if got := buf.String(); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestPrinterSanitize(t *testing.T) {
+	var buf bytes.Buffer
+	printer := NewPrinter(&buf, false)
+	printer.Dump(dump.Dump{
+		Action:      "message",
+		Message:     "a&#27;[2Jb\x1b]0;pwn\x07\tc\nd\u009b2J",
+		FilePath:    "/app/index.php",
+		FileDisplay: "/app/\x1b[1Aindex.php:12",
+		Emote:       "\x1b[31m!",
+		Topic:       "db\r\nPaused x",
+	})
+	printer.Status("pause-continue", "Continued \x1b[2Jabc\n")
+	expected := "#dbPaused x [31m! /app/[1Aindex.php:12\na[2Jb]0;pwn\tc\nd2J\n--\nContinued [2Jabc\n"
+	if got := buf.String(); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		text      string
+		multiline bool
+		expected  string
+	}{
+		{"plain 🐘", false, "plain 🐘"},
+		{"a\tb\nc", true, "a\tb\nc"},
+		{"a\tb\nc", false, "abc"},
+		{"\x1b]0;title\x07", true, "]0;title"},
+		{"\u0085\u009b\x7f", true, ""},
+		{"invalid \x9b", true, "invalid \uFFFD"},
+	}
+	for _, tt := range tests {
+		if got := sanitize(tt.text, tt.multiline); got != tt.expected {
+			t.Errorf("Expected sanitize(%q, %v) %q, got %q", tt.text, tt.multiline, tt.expected, got)
+		}
+	}
+}
+
+func TestPrinterColor(t *testing.T) {
+	var buf bytes.Buffer
+	NewPrinter(&buf, true).Dump(dump.Dump{Action: "message", Message: "hi", Topic: "db"})
+	if !strings.Contains(buf.String(), cyan+"#db"+reset) {
+		t.Errorf("Expected colored topic, got %q", buf.String())
+	}
+	buf.Reset()
+	NewPrinter(&buf, true).Status("pause-continue", "Continued abc")
+	if got := buf.String(); got != green+"Continued abc"+reset+"\n" {
+		t.Errorf("Expected colored status, got %q", got)
+	}
+}
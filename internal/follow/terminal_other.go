@@ -0,0 +1,22 @@
+//go:build !linux && !darwin && !freebsd
+
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package follow
+
+import (
+	"errors"
+	"os"
+)
+
+// KeyMode isn't supported on this platform, so commands are read by line.
+func KeyMode(f *os.File) (func() error, error) {
+	return nil, errors.ErrUnsupported
+}
@@ -0,0 +1,48 @@
+//go:build linux || darwin || freebsd
+
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package follow
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// KeyMode switches the terminal to read single key presses without echo,
+// keeping the signals such as Ctrl+C. It fails when f isn't a terminal.
+// The returned function restores the previous mode.
+func KeyMode(f *os.File) (func() error, error) {
+	fd := f.Fd()
+	var original syscall.Termios
+	if err := termios(fd, ioctlGetTermios, &original); err != nil {
+		return nil, err
+	}
+	keys := original
+	keys.Lflag &^= syscall.ICANON | syscall.ECHO
+	keys.Cc[syscall.VMIN] = 1
+	keys.Cc[syscall.VTIME] = 0
+	if err := termios(fd, ioctlSetTermios, &keys); err != nil {
+		return nil, err
+	}
+	return func() error {
+		return termios(fd, ioctlSetTermios, &original)
+	}, nil
+}
+
+// termios gets or sets the terminal attributes of fd.
+func termios(fd uintptr, request uintptr, t *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(unsafe.Pointer(t)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+// Package form parses request fields from url-encoded or JSON bodies.
+package form
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/xrdebug/xrdebug/internal/dump"
+)
+
+// Error is the JSON body of the rejected requests.
+type Error struct {
+	// Error describes why the request was rejected
+	Error string `json:"error"`
+	// Fields maps each invalid field to its reason
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// WriteError writes err as a JSON error response. Bodies over the size limit
+// are reported as 413 and validation errors list the invalid fields, while
+// any other error is reported with the given status and message.
+func WriteError(w http.ResponseWriter, err error, status int, message string) {
+	body := Error{Error: message}
+	var maxBytesErr *http.MaxBytesError
+	var validationErr *dump.ValidationError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		status = http.StatusRequestEntityTooLarge
+		body.Error = fmt.Sprintf("Request body exceeds %d bytes", maxBytesErr.Limit)
+	case errors.As(err, &validationErr):
+		body.Fields = validationErr.Fields
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// Item is a batch entry with its fields or the error found decoding it.
+type Item struct {
+	// Values holds the item fields
+	Values url.Values
+	// Err is the decoding error for the item, if any
+	Err error
+}
+
+// IsJSON reports whether the request body is declared as JSON.
+func IsJSON(r *http.Request) bool {
+	return mediaType(r) == "application/json"
+}
+
+// IsNDJSON reports whether the request body is declared as newline delimited JSON.
+func IsNDJSON(r *http.Request) bool {
+	return mediaType(r) == "application/x-ndjson"
+}
+
+// IsBatch reports whether the request body is a batch, either a JSON array or NDJSON.
+// The body remains readable.
+func IsBatch(r *http.Request) (bool, error) {
+	if IsNDJSON(r) {
+		return true, nil
+	}
+	if !IsJSON(r) {
+		return false, nil
+	}
+	body, err := Body(r)
+	if err != nil {
+		return false, err
+	}
+	trimmed := bytes.TrimSpace(body)
+	return len(trimmed) > 0 && trimmed[0] == '[', nil
+}
+
+// Body reads the whole request body, leaving it readable for the next reader.
+func Body(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// Batch decodes the items of a JSON array or NDJSON body, in order.
+// It returns an error when the body itself can't be split into items,
+// while each item carries its own decoding error.
+func Batch(r *http.Request) ([]Item, error) {
+	body, err := Body(r)
+	if err != nil {
+		return nil, err
+	}
+	var raws [][]byte
+	if IsNDJSON(r) {
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		scanner.Buffer(nil, len(body)+1)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) > 0 {
+				raws = append(raws, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	} else {
+		var items []json.RawMessage
+		if err := json.Unmarshal(body, &items); err != nil {
+			return nil, fmt.Errorf("invalid JSON array: %w", err)
+		}
+		for _, item := range items {
+			raws = append(raws, item)
+		}
+	}
+	items := make([]Item, 0, len(raws))
+	for _, raw := range raws {
+		values, err := FromJSON(raw)
+		items = append(items, Item{Values: values, Err: err})
+	}
+	return items, nil
+}
+
+// mediaType returns the media type of the request body.
+func mediaType(r *http.Request) string {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType
+}
+
+// Parse populates r.Form and r.PostForm from the request body.
+// JSON objects are flattened to their scalar fields, so handlers keep reading
+// them with r.FormValue regardless of the content type. The body remains
+// readable after parsing JSON.
+func Parse(r *http.Request) error {
+	if !IsJSON(r) {
+		return r.ParseForm()
+	}
+	if r.PostForm != nil {
+		return nil
+	}
+	body, err := Body(r)
+	if err != nil {
+		return err
+	}
+	values, err := FromJSON(body)
+	if err != nil {
+		return err
+	}
+	r.PostForm = values
+	r.Form = make(url.Values)
+	for k, v := range r.URL.Query() {
+		r.Form[k] = v
+	}
+	for k, v := range values {
+		r.Form[k] = v
+	}
+	return nil
+}
+
+// FromJSON decodes a JSON object of scalar fields into url.Values.
+// Strings are taken as-is, numbers as written in the document, booleans as
+// `true` or `false` and null as an empty string.
+func FromJSON(data []byte) (url.Values, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var fields map[string]any
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, fmt.Errorf("invalid JSON object: %w", err)
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("invalid JSON object: unexpected data after object")
+	}
+	values := make(url.Values, len(fields))
+	for k, v := range fields {
+		switch value := v.(type) {
+		case string:
+			values.Set(k, value)
+		case json.Number:
+			values.Set(k, value.String())
+		case bool:
+			values.Set(k, strconv.FormatBool(value))
+		case nil:
+			values.Set(k, "")
+		default:
+			return nil, fmt.Errorf("invalid JSON object: field `%s` must be a scalar", k)
+		}
+	}
+	return values, nil
+}
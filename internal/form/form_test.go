@@ -0,0 +1,247 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package form
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xrdebug/xrdebug/internal/dump"
+)
+
+func TestFromJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected url.Values
+		error    bool
+	}{
+		{
+			name:  "scalar fields",
+			input: `{"body":"test","file_line":10,"emote":null,"flag":true}`,
+			expected: url.Values{
+				"body":      {"test"},
+				"file_line": {"10"},
+				"emote":     {""},
+				"flag":      {"true"},
+			},
+		},
+		{
+			name:     "number as written",
+			input:    `{"file_line":1.50}`,
+			expected: url.Values{"file_line": {"1.50"}},
+		},
+		{
+			name:  "nested object",
+			input: `{"body":{"a":1}}`,
+			error: true,
+		},
+		{
+			name:  "array",
+			input: `["body"]`,
+			error: true,
+		},
+		{
+			name:  "trailing data",
+			input: `{"body":"a"}{"body":"b"}`,
+			error: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromJSON([]byte(tt.input))
+			if tt.error {
+				if err == nil {
+					t.Errorf("FromJSON() expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FromJSON() unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("FromJSON() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{"form", "application/x-www-form-urlencoded", "body=test&file_line=10"},
+		{"json", "application/json; charset=utf-8", `{"body":"test","file_line":10}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/messages?topic=query", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			if err := Parse(req); err != nil {
+				t.Fatal(err)
+			}
+			if got := req.FormValue("body"); got != "test" {
+				t.Errorf("Expected body test, got %q", got)
+			}
+			if got := req.FormValue("file_line"); got != "10" {
+				t.Errorf("Expected file_line 10, got %q", got)
+			}
+			if got := req.FormValue("topic"); got != "query" {
+				t.Errorf("Expected topic query, got %q", got)
+			}
+		})
+	}
+	t.Run("json body remains readable", func(t *testing.T) {
+		body := `{"body":"test"}`
+		req := httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if err := Parse(req); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := io.ReadAll(req.Body)
+		if string(got) != body {
+			t.Errorf("Expected body %q, got %q", body, got)
+		}
+	})
+	t.Run("invalid json", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader("{"))
+		req.Header.Set("Content-Type", "application/json")
+		if err := Parse(req); err == nil {
+			t.Error("Expected error for invalid JSON")
+		}
+	})
+}
+
+func TestBatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		bodies      []string
+		errors      []bool
+		error       bool
+	}{
+		{
+			name:        "json array",
+			contentType: "application/json",
+			body:        `[{"body":"a"},{"body":"b"},[1]]`,
+			bodies:      []string{"a", "b", ""},
+			errors:      []bool{false, false, true},
+		},
+		{
+			name:        "ndjson",
+			contentType: "application/x-ndjson",
+			body:        "{\"body\":\"a\"}\n\n{\"body\":\"b\"}\n{\n",
+			bodies:      []string{"a", "b", ""},
+			errors:      []bool{false, false, true},
+		},
+		{
+			name:        "invalid array",
+			contentType: "application/json",
+			body:        `[{"body":"a"}`,
+			error:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/messages/batch", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			isBatch, err := IsBatch(req)
+			if err != nil || !isBatch {
+				t.Fatalf("IsBatch() = %v, %v", isBatch, err)
+			}
+			items, err := Batch(req)
+			if tt.error {
+				if err == nil {
+					t.Error("Batch() expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(items) != len(tt.bodies) {
+				t.Fatalf("Expected %d items, got %d", len(tt.bodies), len(items))
+			}
+			for i, item := range items {
+				if (item.Err != nil) != tt.errors[i] {
+					t.Errorf("Item %d: unexpected error state %v", i, item.Err)
+				}
+				if got := item.Values.Get("body"); got != tt.bodies[i] {
+					t.Errorf("Item %d: expected body %q, got %q", i, tt.bodies[i], got)
+				}
+			}
+		})
+	}
+}
+
+func TestIsBatch(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(`{"body":"a"}`))
+	req.Header.Set("Content-Type", "application/json")
+	if isBatch, _ := IsBatch(req); isBatch {
+		t.Error("Expected JSON object not to be a batch")
+	}
+	if err := Parse(req); err != nil || req.FormValue("body") != "a" {
+		t.Errorf("Expected body to remain readable, got %v", err)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		status   int
+		expected Error
+	}{
+		{
+			name:     "plain error",
+			err:      errors.New("plain"),
+			status:   http.StatusBadRequest,
+			expected: Error{Error: "Bad"},
+		},
+		{
+			name:     "validation error",
+			err:      &dump.ValidationError{Fields: map[string]string{"file_line": "must be an integer"}},
+			status:   http.StatusBadRequest,
+			expected: Error{Error: "Bad", Fields: map[string]string{"file_line": "must be an integer"}},
+		},
+		{
+			name:     "body too large",
+			err:      &http.MaxBytesError{Limit: 10},
+			status:   http.StatusRequestEntityTooLarge,
+			expected: Error{Error: "Request body exceeds 10 bytes"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			WriteError(w, tt.err, http.StatusBadRequest, "Bad")
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, w.Code)
+			}
+			var got Error
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+// Package history provides a bounded in-memory store of broadcasted messages.
+package history
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry represents a stored message with its sequence identifier
+type Entry struct {
+	// ID is the monotonically increasing identifier of the entry
+	ID uint64
+	// Epoch identifies the Store that appended the entry
+	Epoch string
+	// Event is the type of the entry
+	Event string
+	// Data holds the message payload as sent to the clients
+	Data string
+	// Time is the moment the entry was appended
+	Time time.Time
+}
+
+// EventID returns the identifier sent to the stream clients, the ID
+// prefixed by the epoch as `<epoch>-<id>`
+func (e Entry) EventID() string {
+	return e.Epoch + "-" + strconv.FormatUint(e.ID, 10)
+}
+
+// Store keeps the most recent entries bounded by size and age
+type Store struct {
+	mu      sync.Mutex
+	entries []Entry
+	size    int
+	maxAge  time.Duration
+	lastID  uint64
+	epoch   string
+	now     func() time.Time
+}
+
+// New creates a Store holding up to size entries not older than maxAge.
+// A size of zero disables storage (IDs are still assigned) and a maxAge of
+// zero disables age based eviction. The epoch is taken from the creation
+// time, so the IDs of a restarted server are told apart.
+func New(size int, maxAge time.Duration) *Store {
+	if size < 0 {
+		size = 0
+	}
+	return &Store{
+		entries: make([]Entry, 0, size),
+		size:    size,
+		maxAge:  maxAge,
+		epoch:   strconv.FormatInt(time.Now().UnixNano(), 36),
+		now:     time.Now,
+	}
+}
+
+// Append stores data for the given event type as a new entry and returns it
+func (s *Store) Append(event, data string) Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastID++
+	entry := Entry{ID: s.lastID, Epoch: s.epoch, Event: event, Data: data, Time: s.now()}
+	if s.size == 0 {
+		return entry
+	}
+	if len(s.entries) == s.size {
+		copy(s.entries, s.entries[1:])
+		s.entries = s.entries[:len(s.entries)-1]
+	}
+	s.entries = append(s.entries, entry)
+	s.evictExpired()
+	return entry
+}
+
+// Since returns the stored entries with an ID greater than id.
+// If id is ahead of the last assigned ID (the server was restarted since the
+// client last connected) all the stored entries are returned.
+func (s *Store) Since(id uint64) []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evictExpired()
+	if id > s.lastID {
+		id = 0
+	}
+	var entries []Entry
+	for _, entry := range s.entries {
+		if entry.ID > id {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// SinceEvent returns the stored entries after the given event identifier.
+// All the stored entries are returned for an identifier from another epoch
+// (the server was restarted since the client last connected) or an invalid
+// one.
+func (s *Store) SinceEvent(eventID string) []Entry {
+	epoch, id, found := strings.Cut(eventID, "-")
+	if !found || epoch != s.Epoch() {
+		return s.Since(0)
+	}
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return s.Since(0)
+	}
+	return s.Since(n)
+}
+
+// Clear removes all the stored entries, IDs keep increasing
+func (s *Store) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = s.entries[:0]
+}
+
+// Epoch returns the epoch of the entries appended to the store
+func (s *Store) Epoch() string {
+	return s.epoch
+}
+
+// LastID returns the ID of the last appended entry
+func (s *Store) LastID() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastID
+}
+
+// Len returns the number of stored entries
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evictExpired()
+	return len(s.entries)
+}
+
+// evictExpired drops the entries older than maxAge, callers must hold mu
+func (s *Store) evictExpired() {
+	if s.maxAge <= 0 {
+		return
+	}
+	cutoff := s.now().Add(-s.maxAge)
+	i := 0
+	for i < len(s.entries) && s.entries[i].Time.Before(cutoff) {
+		i++
+	}
+	if i > 0 {
+		s.entries = append(s.entries[:0], s.entries[i:]...)
+	}
+}
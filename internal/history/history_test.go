@@ -0,0 +1,128 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package history
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	store := New(3, 0)
+	for _, data := range []string{"a", "b", "c", "d"} {
+		store.Append("message", data)
+	}
+	t.Run("bounded by size", func(t *testing.T) {
+		entries := store.Since(0)
+		if len(entries) != 3 {
+			t.Fatalf("Expected 3 entries, got %d", len(entries))
+		}
+		if entries[0].Data != "b" || entries[0].ID != 2 {
+			t.Errorf("Expected oldest entry b#2, got %s#%d", entries[0].Data, entries[0].ID)
+		}
+	})
+	t.Run("since id", func(t *testing.T) {
+		entries := store.Since(3)
+		if len(entries) != 1 || entries[0].Data != "d" {
+			t.Errorf("Expected only entry d, got %v", entries)
+		}
+	})
+	t.Run("up to date", func(t *testing.T) {
+		if entries := store.Since(4); len(entries) != 0 {
+			t.Errorf("Expected no entries, got %v", entries)
+		}
+	})
+	t.Run("id ahead of last id", func(t *testing.T) {
+		if entries := store.Since(99); len(entries) != 3 {
+			t.Errorf("Expected all 3 entries, got %d", len(entries))
+		}
+	})
+}
+
+func TestStoreSinceEvent(t *testing.T) {
+	store := New(10, 0)
+	for _, data := range []string{"a", "b", "c"} {
+		store.Append("message", data)
+	}
+	epoch := store.Epoch()
+	tests := []struct {
+		eventID  string
+		expected int
+	}{
+		{"", 3},
+		{epoch + "-1", 2},
+		{epoch + "-3", 0},
+		{epoch + "-x", 3},
+		{"2", 3},
+		{"other-1", 3},
+	}
+	for _, tt := range tests {
+		if entries := store.SinceEvent(tt.eventID); len(entries) != tt.expected {
+			t.Errorf("Expected %d entries since %q, got %d", tt.expected, tt.eventID, len(entries))
+		}
+	}
+	if entry := store.Append("message", "d"); entry.EventID() != epoch+"-4" {
+		t.Errorf("Expected event ID %s-4, got %s", epoch, entry.EventID())
+	}
+}
+
+func TestStoreRestart(t *testing.T) {
+	before := New(10, 0)
+	before.epoch = "before"
+	var lastEventID string
+	for _, data := range []string{"a", "b", "c", "d", "e"} {
+		lastEventID = before.Append("message", data).EventID()
+	}
+	after := New(10, 0)
+	after.epoch = "after"
+	after.Append("message", "f")
+	after.Append("message", "g")
+	entries := after.SinceEvent(lastEventID)
+	if len(entries) != 2 || entries[0].Data != "f" || entries[1].Data != "g" {
+		t.Errorf("Expected entries f and g after the restart, got %v", entries)
+	}
+}
+
+func TestStoreMaxAge(t *testing.T) {
+	now := time.Now()
+	store := New(10, time.Minute)
+	store.now = func() time.Time { return now }
+	store.Append("message", "old")
+	now = now.Add(2 * time.Minute)
+	store.Append("message", "new")
+	entries := store.Since(0)
+	if len(entries) != 1 || entries[0].Data != "new" {
+		t.Errorf("Expected only the new entry, got %v", entries)
+	}
+}
+
+func TestStoreDisabled(t *testing.T) {
+	store := New(0, 0)
+	entry := store.Append("message", "a")
+	if entry.ID != 1 {
+		t.Errorf("Expected ID 1, got %d", entry.ID)
+	}
+	if store.Len() != 0 {
+		t.Errorf("Expected no stored entries, got %d", store.Len())
+	}
+}
+
+func TestStoreClear(t *testing.T) {
+	store := New(10, 0)
+	store.Append("message", "a")
+	store.Clear()
+	entry := store.Append("clear", "")
+	if entry.ID != 2 {
+		t.Errorf("Expected ID 2, got %d", entry.ID)
+	}
+	if entries := store.Since(0); len(entries) != 1 || entries[0].Event != "clear" {
+		t.Errorf("Expected only the clear entry, got %v", entries)
+	}
+}
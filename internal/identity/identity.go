@@ -0,0 +1,60 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+// Package identity carries the identity of the client that signed a
+// request through its context.
+package identity
+
+import "context"
+
+// key is the context key of the client identity
+type key struct{}
+
+// holder keeps the client identity, shared by the contexts derived from
+// the one it was stored in
+type holder struct {
+	id string
+}
+
+// Track returns a copy of ctx where the identity stored by With on any
+// derived context is also visible, so middlewares wrapping the signature
+// verification can report it.
+func Track(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(key{}).(*holder); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, key{}, &holder{})
+}
+
+// With returns ctx carrying the client identity. When ctx is tracked the
+// identity is stored in place and ctx is returned.
+func With(ctx context.Context, id string) context.Context {
+	if h, ok := ctx.Value(key{}).(*holder); ok {
+		h.id = id
+		return ctx
+	}
+	return context.WithValue(ctx, key{}, &holder{id: id})
+}
+
+// From returns the client identity carried by ctx, empty when unknown.
+func From(ctx context.Context) string {
+	if h, ok := ctx.Value(key{}).(*holder); ok {
+		return h.id
+	}
+	return ""
+}
+
+// Fields returns the log fields with the client identity appended when
+// known.
+func Fields(ctx context.Context, fields ...any) []any {
+	if id := From(ctx); id != "" {
+		fields = append(fields, "client", id)
+	}
+	return fields
+}
@@ -0,0 +1,52 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package identity
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		expected []any
+	}{
+		{"unknown", context.Background(), []any{"route", "/messages"}},
+		{"empty", With(context.Background(), ""), []any{"route", "/messages"}},
+		{"known", With(context.Background(), "laptop"), []any{"route", "/messages", "client", "laptop"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Fields(tt.ctx, "route", "/messages"); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTrack(t *testing.T) {
+	tracked := Track(context.Background())
+	if Track(tracked) != tracked {
+		t.Error("Expected a tracked context to be kept")
+	}
+	derived := context.WithValue(tracked, struct{}{}, "value")
+	if got := From(With(derived, "laptop")); got != "laptop" {
+		t.Errorf("Expected laptop, got %s", got)
+	}
+	if got := From(tracked); got != "laptop" {
+		t.Errorf("Expected laptop in the tracked context, got %s", got)
+	}
+	if got := From(context.Background()); got != "" {
+		t.Errorf("Expected no identity, got %s", got)
+	}
+}
@@ -0,0 +1,89 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package logfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileRotation(t *testing.T) {
+	tests := []struct {
+		name     string
+		backups  int
+		expected map[string]string
+	}{
+		{
+			name:    "with backups",
+			backups: 2,
+			expected: map[string]string{
+				"access.log":   "dddd\n",
+				"access.log.1": "cccc\n",
+				"access.log.2": "bbbb\n",
+			},
+		},
+		{
+			name:    "without backups",
+			backups: 0,
+			expected: map[string]string{
+				"access.log": "dddd\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "access.log")
+			f, err := Open(path, 8, tt.backups)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, line := range []string{"aaaa\n", "bbbb\n", "cccc\n", "dddd\n"} {
+				if _, err := f.Write([]byte(line)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != len(tt.expected) {
+				t.Errorf("Expected %d files, got %d", len(tt.expected), len(entries))
+			}
+			for name, content := range tt.expected {
+				got, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != content {
+					t.Errorf("Expected %s to contain %q, got %q", name, content, got)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	if err := os.WriteFile(path, []byte("old\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Open(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("new\n"))
+	f.Close()
+	got, _ := os.ReadFile(path)
+	if string(got) != "old\nnew\n" {
+		t.Errorf("Expected appended content, got %q", got)
+	}
+}
@@ -0,0 +1,31 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package metrics
+
+// Default is the registry exposed by the server
+var Default = NewRegistry()
+
+var (
+	// MessagesReceived counts the accepted messages
+	MessagesReceived = Default.Counter("xrdebug_messages_received_total",
+		"Number of messages received.")
+	// Pauses counts the pause lock changes by action (created, stopped, continued, expired)
+	Pauses = Default.Counter("xrdebug_pauses_total",
+		"Number of pause lock changes by action.", "action")
+	// SignatureFailures counts the requests rejected by signature verification by reason
+	SignatureFailures = Default.Counter("xrdebug_signature_failures_total",
+		"Number of requests rejected by signature verification.", "reason")
+	// EncryptionOperations counts the messages encrypted for the stream
+	EncryptionOperations = Default.Counter("xrdebug_encryption_operations_total",
+		"Number of messages encrypted for the stream.")
+	// RequestDuration observes the request latency by route and status code
+	RequestDuration = Default.Histogram("xrdebug_http_request_duration_seconds",
+		"Latency of the HTTP requests by route and status code.", DefaultBuckets, "route", "code")
+)
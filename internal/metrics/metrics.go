@@ -0,0 +1,278 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+// Package metrics provides counters, gauges and histograms exposed in the
+// Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// contentType is the media type of the Prometheus text exposition format
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the latency histogram buckets, in seconds
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// collector writes the samples of a metric family
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+// Registry holds the metrics exposed by its handler, in registration order
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// Handler returns an http.Handler writing all the metrics
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		r.Write(w)
+	})
+}
+
+// Write writes all the metrics in the text exposition format
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	collectors := append([]collector{}, r.collectors...)
+	r.mu.Unlock()
+	buf := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.write(buf)
+	}
+	return buf.Flush()
+}
+
+// series holds the label values of a sample, keyed by their joined form
+type series struct {
+	mu     sync.Mutex
+	labels []string
+	keys   []string
+	values map[string][]string
+}
+
+func newSeries(labels []string) *series {
+	return &series{labels: labels, values: make(map[string][]string)}
+}
+
+// key returns the series key for the label values, adding it when new.
+// It must be called with the lock held.
+func (s *series) key(labelValues []string) string {
+	if len(labelValues) != len(s.labels) {
+		panic(fmt.Sprintf("metrics: expected %d label values, got %d", len(s.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	if _, found := s.values[key]; !found {
+		s.values[key] = append([]string{}, labelValues...)
+		s.keys = append(s.keys, key)
+		sort.Strings(s.keys)
+	}
+	return key
+}
+
+// format returns the label pairs for the key, with the extra pairs appended.
+func (s *series) format(key string, extra ...string) string {
+	pairs := make([]string, 0, len(s.labels)+len(extra)/2)
+	for i, value := range s.values[key] {
+		pairs = append(pairs, s.labels[i]+`="`+escape(value)+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escape(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Counter is a monotonically increasing value, partitioned by labels
+type Counter struct {
+	name   string
+	help   string
+	series *series
+	counts map[string]float64
+}
+
+// Counter registers a new Counter with the given label names
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	c := &Counter{
+		name:   name,
+		help:   help,
+		series: newSeries(labels),
+		counts: make(map[string]float64),
+	}
+	r.register(c)
+	return c
+}
+
+// Inc increments the counter for the label values by one
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increments the counter for the label values by n
+func (c *Counter) Add(n float64, labelValues ...string) {
+	c.series.mu.Lock()
+	defer c.series.mu.Unlock()
+	c.counts[c.series.key(labelValues)] += n
+}
+
+// Value returns the counter value for the label values
+func (c *Counter) Value(labelValues ...string) float64 {
+	c.series.mu.Lock()
+	defer c.series.mu.Unlock()
+	return c.counts[strings.Join(labelValues, "\xff")]
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.series.mu.Lock()
+	defer c.series.mu.Unlock()
+	header(w, c.name, c.help, "counter")
+	if len(c.series.labels) == 0 && len(c.counts) == 0 {
+		sample(w, c.name, "", 0)
+	}
+	for _, key := range c.series.keys {
+		sample(w, c.name, c.series.format(key), c.counts[key])
+	}
+}
+
+// funcMetric is a metric whose samples are collected when written
+type funcMetric struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	collect func(set func(value float64, labelValues ...string))
+}
+
+// GaugeFunc registers a gauge whose samples are set by collect on each scrape
+func (r *Registry) GaugeFunc(name, help string, labels []string, collect func(set func(value float64, labelValues ...string))) {
+	r.register(&funcMetric{name: name, help: help, kind: "gauge", labels: labels, collect: collect})
+}
+
+// CounterFunc registers a counter whose samples are set by collect on each scrape
+func (r *Registry) CounterFunc(name, help string, labels []string, collect func(set func(value float64, labelValues ...string))) {
+	r.register(&funcMetric{name: name, help: help, kind: "counter", labels: labels, collect: collect})
+}
+
+func (m *funcMetric) write(w *bufio.Writer) {
+	s := newSeries(m.labels)
+	values := make(map[string]float64)
+	m.collect(func(value float64, labelValues ...string) {
+		values[s.key(labelValues)] = value
+	})
+	header(w, m.name, m.help, m.kind)
+	for _, key := range s.keys {
+		sample(w, m.name, s.format(key), values[key])
+	}
+}
+
+// Histogram samples observations in buckets, partitioned by labels
+type Histogram struct {
+	name    string
+	help    string
+	buckets []float64
+	series  *series
+	counts  map[string][]uint64
+	sums    map[string]float64
+	totals  map[string]uint64
+}
+
+// Histogram registers a new Histogram with the given buckets and label names
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		name:    name,
+		help:    help,
+		buckets: buckets,
+		series:  newSeries(labels),
+		counts:  make(map[string][]uint64),
+		sums:    make(map[string]float64),
+		totals:  make(map[string]uint64),
+	}
+	r.register(h)
+	return h
+}
+
+// Observe adds the value to the histogram for the label values
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.series.mu.Lock()
+	defer h.series.mu.Unlock()
+	key := h.series.key(labelValues)
+	counts, found := h.counts[key]
+	if !found {
+		counts = make([]uint64, len(h.buckets))
+		h.counts[key] = counts
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			counts[i]++
+		}
+	}
+	h.sums[key] += value
+	h.totals[key]++
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.series.mu.Lock()
+	defer h.series.mu.Unlock()
+	header(w, h.name, h.help, "histogram")
+	for _, key := range h.series.keys {
+		for i, bound := range h.buckets {
+			sample(w, h.name+"_bucket", h.series.format(key, "le", formatFloat(bound)), float64(h.counts[key][i]))
+		}
+		sample(w, h.name+"_bucket", h.series.format(key, "le", "+Inf"), float64(h.totals[key]))
+		sample(w, h.name+"_sum", h.series.format(key), h.sums[key])
+		sample(w, h.name+"_count", h.series.format(key), float64(h.totals[key]))
+	}
+}
+
+// header writes the HELP and TYPE lines of a metric family
+func header(w *bufio.Writer, name, help, kind string) {
+	help = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sample writes a single sample line
+func sample(w *bufio.Writer, name, labels string, value float64) {
+	fmt.Fprintf(w, "%s%s %s\n", name, labels, formatFloat(value))
+}
+
+// escape escapes a label value
+func escape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
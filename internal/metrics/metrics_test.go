@@ -0,0 +1,74 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	total := registry.Counter("test_total", "Total count.")
+	actions := registry.Counter("test_actions_total", "Actions.", "action")
+	latency := registry.Histogram("test_seconds", "Latency.", []float64{0.1, 1}, "route")
+	registry.GaugeFunc("test_clients", "Clients.", []string{"session"}, func(set func(float64, ...string)) {
+		set(2, "b")
+		set(1, `a"b`)
+	})
+	actions.Inc("stop")
+	actions.Add(2, "continue")
+	latency.Observe(0.05, "GET /")
+	latency.Observe(0.5, "GET /")
+	latency.Observe(3, "GET /")
+	expected := `# HELP test_total Total count.
+# TYPE test_total counter
+test_total 0
+# HELP test_actions_total Actions.
+# TYPE test_actions_total counter
+test_actions_total{action="continue"} 2
+test_actions_total{action="stop"} 1
+# HELP test_seconds Latency.
+# TYPE test_seconds histogram
+test_seconds_bucket{route="GET /",le="0.1"} 1
+test_seconds_bucket{route="GET /",le="1"} 2
+test_seconds_bucket{route="GET /",le="+Inf"} 3
+test_seconds_sum{route="GET /"} 3.55
+test_seconds_count{route="GET /"} 3
+# HELP test_clients Clients.
+# TYPE test_clients gauge
+test_clients{session="a\"b"} 1
+test_clients{session="b"} 2
+`
+	w := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if got := w.Body.String(); got != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, got)
+	}
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("Expected text exposition content type, got %q", got)
+	}
+	total.Inc()
+	if total.Value() != 1 {
+		t.Errorf("Expected 1, got %v", total.Value())
+	}
+}
+
+func TestCounterLabelMismatch(t *testing.T) {
+	counter := NewRegistry().Counter("test_total", "Total.", "action")
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for missing label values")
+		}
+	}()
+	counter.Inc()
+}
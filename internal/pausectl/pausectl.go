@@ -11,7 +11,9 @@
 package pausectl
 
 import (
+	"context"
 	"errors"
+	"sync"
 	"time"
 
 	"github.com/patrickmn/go-cache"
@@ -28,26 +30,81 @@ type Lock struct {
 	Stop bool   `json:"stop"`
 }
 
+// entry is the cached state of a lock, its changed channel is closed when
+// the lock is updated or removed.
+type entry struct {
+	stop    bool
+	ttl     time.Duration
+	changed chan struct{}
+	once    sync.Once
+}
+
+func newEntry(stop bool, ttl time.Duration) *entry {
+	return &entry{stop: stop, ttl: ttl, changed: make(chan struct{})}
+}
+
+// notify wakes up the waiters of the entry
+func (e *entry) notify() {
+	e.once.Do(func() {
+		close(e.changed)
+	})
+}
+
 // Manager handles the creation and management of pause locks
 type Manager struct {
 	cache      *cache.Cache
 	expiration time.Duration
+	mu         sync.Mutex
+	deleting   map[string]bool
+	onExpired  func(id string)
 }
 
 // NewManager creates a new Manager with the specified expiration and cleanup intervals
 func NewManager(expiration, cleanupInterval time.Duration) *Manager {
-	return &Manager{
+	m := &Manager{
 		cache:      cache.New(expiration, cleanupInterval),
 		expiration: expiration,
+		deleting:   make(map[string]bool),
 	}
+	m.cache.OnEvicted(m.evicted)
+	return m
 }
 
-// New creates a new Lock with the specified ID
+// evicted notifies the waiters of a removed lock, reporting it to the
+// OnExpired function unless it was removed with Delete.
+func (m *Manager) evicted(id string, value interface{}) {
+	value.(*entry).notify()
+	m.mu.Lock()
+	deleting := m.deleting[id]
+	onExpired := m.onExpired
+	m.mu.Unlock()
+	if !deleting && onExpired != nil {
+		onExpired(id)
+	}
+}
+
+// OnExpired sets the function called with the ID of each lock removed by
+// expiration, locks removed with Delete are not reported.
+func (m *Manager) OnExpired(fn func(id string)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onExpired = fn
+}
+
+// New creates a new Lock with the specified ID and the default expiration
 func (m *Manager) New(id string) (*Lock, error) {
-	if _, found := m.cache.Get(id); found {
+	return m.NewWithTTL(id, 0)
+}
+
+// NewWithTTL creates a new Lock with the specified ID expiring after ttl,
+// or after the default expiration when ttl is zero.
+func (m *Manager) NewWithTTL(id string, ttl time.Duration) (*Lock, error) {
+	if ttl <= 0 {
+		ttl = m.expiration
+	}
+	if err := m.cache.Add(id, newEntry(false, ttl), ttl); err != nil {
 		return nil, ErrLockExists
 	}
-	m.cache.Set(id, false, m.expiration)
 	return &Lock{id, false}, nil
 }
 
@@ -57,19 +114,85 @@ func (m *Manager) Get(id string) (*Lock, error) {
 	if !found {
 		return nil, ErrLockNotFound
 	}
-	return &Lock{id, value.(bool)}, nil
+	return &Lock{id, value.(*entry).stop}, nil
 }
 
-// Update sets the stop status of a Lock to true
+// Wait blocks until the Lock is updated or removed, returning its state at
+// that point. Stopped locks return at once and ctx ending returns the
+// current state, it returns ErrLockNotFound once the lock is removed.
+func (m *Manager) Wait(ctx context.Context, id string) (*Lock, error) {
+	value, found := m.cache.Get(id)
+	if !found {
+		return nil, ErrLockNotFound
+	}
+	e := value.(*entry)
+	if e.stop {
+		return &Lock{id, true}, nil
+	}
+	select {
+	case <-e.changed:
+	case <-ctx.Done():
+	}
+	return m.Get(id)
+}
+
+// Update sets the stop status of a Lock to true, restarting its expiration
 func (m *Manager) Update(id string) (*Lock, error) {
-	if _, found := m.cache.Get(id); !found {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, found := m.cache.Get(id)
+	if !found {
 		return nil, ErrLockNotFound
 	}
-	m.cache.Set(id, true, m.expiration)
+	e := value.(*entry)
+	m.cache.Set(id, newEntry(true, e.ttl), e.ttl)
+	e.notify()
 	return &Lock{id, true}, nil
 }
 
+// Refresh restarts the expiration of a Lock, keeping its status
+func (m *Manager) Refresh(id string) (*Lock, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, found := m.cache.Get(id)
+	if !found {
+		return nil, ErrLockNotFound
+	}
+	e := value.(*entry)
+	if err := m.cache.Replace(id, e, e.ttl); err != nil {
+		return nil, ErrLockNotFound
+	}
+	return &Lock{id, e.stop}, nil
+}
+
+// IDs returns the IDs of the current locks
+func (m *Manager) IDs() []string {
+	items := m.cache.Items()
+	ids := make([]string, 0, len(items))
+	for id := range items {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
 // Delete removes a Lock from the manager
 func (m *Manager) Delete(id string) {
+	m.mu.Lock()
+	m.deleting[id] = true
+	m.mu.Unlock()
 	m.cache.Delete(id)
+	m.mu.Lock()
+	delete(m.deleting, id)
+	m.mu.Unlock()
+}
+
+// Close removes every Lock without reporting them. It detaches the cache
+// from the Manager, so its cleanup goroutine stops once the Manager is no
+// longer referenced.
+func (m *Manager) Close() {
+	m.cache.OnEvicted(nil)
+	m.cache.Flush()
+	m.mu.Lock()
+	m.onExpired = nil
+	m.mu.Unlock()
 }
@@ -10,6 +10,7 @@
 package pausectl
 
 import (
+	"context"
 	"testing"
 	"time"
 )
@@ -73,3 +74,101 @@ func TestManager(t *testing.T) {
 		}
 	})
 }
+
+func TestManagerOnExpired(t *testing.T) {
+	manager := NewManager(20*time.Millisecond, 10*time.Millisecond)
+	expired := make(chan string, 2)
+	manager.OnExpired(func(id string) {
+		expired <- id
+	})
+	manager.New("deleted")
+	manager.New("expired")
+	manager.Delete("deleted")
+	select {
+	case id := <-expired:
+		if id != "expired" {
+			t.Errorf("Expected expired lock, got %s", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected lock to expire")
+	}
+	select {
+	case id := <-expired:
+		t.Errorf("Unexpected expiration for %s", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestManagerWait(t *testing.T) {
+	manager := NewManager(5*time.Minute, time.Minute)
+	wait := func(id string, timeout time.Duration) (*Lock, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		return manager.Wait(ctx, id)
+	}
+	if _, err := wait("missing", time.Second); err != ErrLockNotFound {
+		t.Errorf("Expected ErrLockNotFound, got %v", err)
+	}
+	manager.New("lock")
+	lock, err := wait("lock", 10*time.Millisecond)
+	if err != nil || lock.Stop {
+		t.Errorf("Expected running lock on timeout, got %v %v", lock, err)
+	}
+	time.AfterFunc(10*time.Millisecond, func() { manager.Update("lock") })
+	lock, err = wait("lock", 5*time.Second)
+	if err != nil || !lock.Stop {
+		t.Errorf("Expected stopped lock, got %v %v", lock, err)
+	}
+	lock, err = wait("lock", 5*time.Second)
+	if err != nil || !lock.Stop {
+		t.Errorf("Expected stopped lock at once, got %v %v", lock, err)
+	}
+	manager.Delete("lock")
+	manager.New("lock")
+	time.AfterFunc(10*time.Millisecond, func() { manager.Delete("lock") })
+	if _, err := wait("lock", 5*time.Second); err != ErrLockNotFound {
+		t.Errorf("Expected ErrLockNotFound after delete, got %v", err)
+	}
+}
+
+func TestManagerWaitExpired(t *testing.T) {
+	manager := NewManager(20*time.Millisecond, 10*time.Millisecond)
+	manager.New("lock")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := manager.Wait(ctx, "lock"); err != ErrLockNotFound {
+		t.Errorf("Expected ErrLockNotFound after expiration, got %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Error("Expected wait to return on expiration")
+	}
+}
+
+func TestManagerTTL(t *testing.T) {
+	manager := NewManager(time.Minute, 10*time.Millisecond)
+	expired := make(chan string, 1)
+	manager.OnExpired(func(id string) {
+		expired <- id
+	})
+	manager.NewWithTTL("short", 60*time.Millisecond)
+	for range 3 {
+		time.Sleep(30 * time.Millisecond)
+		if _, err := manager.Refresh("short"); err != nil {
+			t.Fatalf("Expected refreshed lock, got %v", err)
+		}
+	}
+	if _, err := manager.Update("short"); err != nil {
+		t.Fatalf("Expected lock to be alive after refreshing, got %v", err)
+	}
+	select {
+	case id := <-expired:
+		if id != "short" {
+			t.Errorf("Expected short lock to expire, got %s", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected lock to expire with its own ttl")
+	}
+	if _, err := manager.Refresh("short"); err != ErrLockNotFound {
+		t.Errorf("Expected ErrLockNotFound, got %v", err)
+	}
+}
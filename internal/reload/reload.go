@@ -0,0 +1,127 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+// Package reload reloads the key and certificate files when they change on
+// disk or when requested, such as on SIGHUP.
+package reload
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xrdebug/xrdebug/internal/cli"
+)
+
+// entry is a load function with the files it reads
+type entry struct {
+	name  string
+	paths []string
+	load  func() error
+	stamp string
+}
+
+// Watcher reloads its entries when their files change. Each load function
+// is expected to keep its current state when it fails.
+type Watcher struct {
+	logger  cli.Logger
+	mu      sync.Mutex
+	entries []*entry
+}
+
+// New creates a Watcher logging each reload to logger.
+func New(logger cli.Logger) *Watcher {
+	return &Watcher{logger: logger}
+}
+
+// Add registers the load function of the given files or directories, named
+// in the logs. Only the changes made after Add trigger a reload.
+func (w *Watcher) Add(name string, load func() error, paths ...string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.entries = append(w.entries, &entry{
+		name:  name,
+		paths: paths,
+		load:  load,
+		stamp: stamp(paths),
+	})
+}
+
+// Check reloads the entries whose files changed since the last check, or
+// every entry when force is true, logging the outcome of each reload.
+func (w *Watcher) Check(force bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, e := range w.entries {
+		current := stamp(e.paths)
+		if !force && current == e.stamp {
+			continue
+		}
+		e.stamp = current
+		if err := e.load(); err != nil {
+			cli.Error(w.logger, "Reload failed", "name", e.name, "error", err)
+			continue
+		}
+		cli.Info(w.logger, "Reloaded", "name", e.name)
+	}
+}
+
+// Run checks the files every interval, use 0 to disable polling, and
+// reloads every entry when a signal is received on signals. It returns when
+// ctx is done.
+func (w *Watcher) Run(ctx context.Context, interval time.Duration, signals <-chan os.Signal) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+			w.Check(false)
+		case sig := <-signals:
+			cli.Info(w.logger, "Reloading", "signal", sig)
+			w.Check(true)
+		}
+	}
+}
+
+// stamp returns the size and modification time of the paths, including
+// the files of directories, which changes when any of them changes.
+func stamp(paths []string) string {
+	var b strings.Builder
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(&b, "%s:missing;", path)
+			continue
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+		if !info.IsDir() {
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			continue
+		}
+		for _, dirEntry := range entries {
+			if info, err := dirEntry.Info(); err == nil {
+				fmt.Fprintf(&b, "%s:%d:%d;", filepath.Join(path, dirEntry.Name()), info.Size(), info.ModTime().UnixNano())
+			}
+		}
+	}
+	return b.String()
+}
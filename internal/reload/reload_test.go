@@ -0,0 +1,158 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package reload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type mockLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (m *mockLogger) Printf(format string, v ...interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, fmt.Sprintf(format, v...))
+}
+
+func (m *mockLogger) last() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.messages) == 0 {
+		return ""
+	}
+	return m.messages[len(m.messages)-1]
+}
+
+// touch writes data to path with a modification time in the future, so the
+// change is noticed regardless of the file system time resolution.
+func touch(t *testing.T, path, data string, offset time.Duration) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	when := time.Now().Add(offset)
+	if err := os.Chtimes(path, when, when); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "key")
+	touch(t, file, "a", 0)
+	logger := &mockLogger{}
+	w := New(logger)
+	loads := 0
+	var loadErr error
+	w.Add("key", func() error {
+		loads++
+		return loadErr
+	}, file)
+	w.Check(false)
+	if loads != 0 {
+		t.Errorf("Expected no reload without changes, got %d", loads)
+	}
+	touch(t, file, "b", time.Minute)
+	w.Check(false)
+	if loads != 1 {
+		t.Errorf("Expected reload on change, got %d", loads)
+	}
+	if !strings.Contains(logger.last(), "Reloaded name=key") {
+		t.Errorf("Expected reload log, got %q", logger.last())
+	}
+	w.Check(false)
+	if loads != 1 {
+		t.Errorf("Expected a single reload per change, got %d", loads)
+	}
+	loadErr = errors.New("invalid key")
+	w.Check(true)
+	if loads != 2 {
+		t.Errorf("Expected forced reload, got %d", loads)
+	}
+	if !strings.Contains(logger.last(), "Reload failed name=key error=invalid key") {
+		t.Errorf("Expected failure log, got %q", logger.last())
+	}
+	os.Remove(file)
+	w.Check(false)
+	if loads != 3 {
+		t.Errorf("Expected reload on removal, got %d", loads)
+	}
+}
+
+func TestCheckDirectory(t *testing.T) {
+	dir := t.TempDir()
+	touch(t, filepath.Join(dir, "a.pub"), "a", 0)
+	w := New(&mockLogger{})
+	loads := 0
+	w.Add("keys", func() error {
+		loads++
+		return nil
+	}, dir)
+	touch(t, filepath.Join(dir, "a.pub"), "changed", time.Minute)
+	w.Check(false)
+	if loads != 1 {
+		t.Errorf("Expected reload on file change, got %d", loads)
+	}
+	touch(t, filepath.Join(dir, "b.pub"), "b", 2*time.Minute)
+	w.Check(false)
+	if loads != 2 {
+		t.Errorf("Expected reload on new file, got %d", loads)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "cert")
+	touch(t, file, "a", 0)
+	w := New(&mockLogger{})
+	reloaded := make(chan struct{}, 10)
+	w.Add("cert", func() error {
+		reloaded <- struct{}{}
+		return nil
+	}, file)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx, time.Millisecond, signals)
+		close(done)
+	}()
+	signals <- syscall.SIGHUP
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second):
+		t.Fatal("Expected reload on signal")
+	}
+	touch(t, file, "b", time.Minute)
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second):
+		t.Fatal("Expected reload on change")
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Run to return when the context is done")
+	}
+}
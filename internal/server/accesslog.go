@@ -0,0 +1,156 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xrdebug/xrdebug/internal/cli"
+	"github.com/xrdebug/xrdebug/internal/identity"
+	"github.com/xrdebug/xrdebug/internal/metrics"
+)
+
+// accessKey is the context key for the access log entry of a request
+type accessKey struct{}
+
+// accessEntry holds the request details reported by the handlers
+type accessEntry struct {
+	reason string
+}
+
+// responseRecorder wraps an http.ResponseWriter capturing the status and
+// the number of bytes written.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher for streaming handlers
+func (r *responseRecorder) Flush() {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+// Unwrap returns the wrapped writer for http.ResponseController
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Reject records the reason why the request was rejected, which is
+// reported by AccessLog. It does nothing for requests not being logged.
+func Reject(r *http.Request, reason string) {
+	if entry, ok := r.Context().Value(accessKey{}).(*accessEntry); ok {
+		entry.reason = reason
+	}
+}
+
+// Identify returns the request carrying the identity of the client that
+// signed it, which is reported by AccessLog and the handler logs.
+func Identify(r *http.Request, client string) *http.Request {
+	return r.WithContext(identity.With(r.Context(), client))
+}
+
+// AccessLog is a middleware that logs every request with its status, bytes
+// written and latency. Requests are logged at warn level for client errors
+// and at error level for server errors. Routes ending with any of the
+// exclude paths (such as `/stream`) are not logged.
+func AccessLog(logger cli.Logger, exclude ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if excluded(r, exclude) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			start := time.Now()
+			entry := &accessEntry{}
+			recorder := &responseRecorder{ResponseWriter: w}
+			ctx := identity.Track(context.WithValue(r.Context(), accessKey{}, entry))
+			next.ServeHTTP(recorder, r.WithContext(ctx))
+			if recorder.status == 0 {
+				recorder.status = http.StatusOK
+			}
+			args := []any{
+				"remote_addr", r.RemoteAddr,
+				"method", r.Method,
+				"route", r.Pattern,
+				"path", r.URL.Path,
+				"status", recorder.status,
+				"bytes", recorder.bytes,
+				"latency", time.Since(start),
+			}
+			args = identity.Fields(ctx, args...)
+			if entry.reason != "" {
+				args = append(args, "reason", entry.reason)
+			}
+			switch {
+			case recorder.status >= http.StatusInternalServerError:
+				cli.Error(logger, "Access", args...)
+			case recorder.status >= http.StatusBadRequest:
+				cli.Warn(logger, "Access", args...)
+			default:
+				cli.Info(logger, "Access", args...)
+			}
+		})
+	}
+}
+
+// Metrics is a middleware that observes the request latency by route and
+// status code. Routes ending with any of the exclude paths are not observed.
+func Metrics(exclude ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if excluded(r, exclude) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			start := time.Now()
+			recorder := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorder, r)
+			if recorder.status == 0 {
+				recorder.status = http.StatusOK
+			}
+			metrics.RequestDuration.Observe(time.Since(start).Seconds(), r.Pattern, strconv.Itoa(recorder.status))
+		})
+	}
+}
+
+// excluded reports whether the request route ends with any of the paths.
+func excluded(r *http.Request, paths []string) bool {
+	_, route, _ := strings.Cut(r.Pattern, " ")
+	for _, path := range paths {
+		if strings.HasSuffix(route, path) {
+			return true
+		}
+	}
+	return false
+}
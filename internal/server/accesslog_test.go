@@ -0,0 +1,116 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package server
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mockLogger struct {
+	messages []string
+}
+
+func (m *mockLogger) Printf(format string, v ...interface{}) {
+	m.messages = append(m.messages, fmt.Sprintf(format, v...))
+}
+
+func TestAccessLog(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		handler  http.Handler
+		expected []string
+	}{
+		{
+			name:   "status and bytes",
+			method: http.MethodPost,
+			path:   "/messages",
+			handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte("hello"))
+			}),
+			expected: []string{"Access ", "method=POST", "path=/messages", "status=201", "bytes=5"},
+		},
+		{
+			name:     "implicit status",
+			method:   http.MethodPost,
+			path:     "/messages",
+			handler:  http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+			expected: []string{"status=200", "bytes=0"},
+		},
+		{
+			name:     "rejected signature",
+			method:   http.MethodPost,
+			path:     "/messages",
+			handler:  VerifySignature(KeySet{"": publicKey}, SignatureConfig{MaxSkew: time.Minute})(http.NotFoundHandler()),
+			expected: []string{"status=401", "reason=Missing signature"},
+		},
+		{
+			name:   "client identity",
+			method: http.MethodPost,
+			path:   "/messages",
+			handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Identify(r, "laptop")
+			}),
+			expected: []string{"status=200", "client=laptop"},
+		},
+		{
+			name:     "excluded route",
+			method:   http.MethodGet,
+			path:     "/stream",
+			handler:  http.NotFoundHandler(),
+			expected: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &mockLogger{}
+			mux := http.NewServeMux()
+			mux.Handle(tt.method+" "+tt.path, AccessLog(logger, "/stream")(tt.handler))
+			mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+			if tt.expected == nil {
+				if len(logger.messages) != 0 {
+					t.Errorf("Expected no log, got %v", logger.messages)
+				}
+				return
+			}
+			if len(logger.messages) != 1 {
+				t.Fatalf("Expected 1 log, got %v", logger.messages)
+			}
+			for _, field := range tt.expected {
+				if !strings.Contains(logger.messages[0], field) {
+					t.Errorf("Expected %q in %q", field, logger.messages[0])
+				}
+			}
+		})
+	}
+}
+
+func TestResponseRecorderController(t *testing.T) {
+	w := httptest.NewRecorder()
+	recorder := &responseRecorder{ResponseWriter: w}
+	if err := http.NewResponseController(recorder).Flush(); err != nil {
+		t.Fatalf("Expected flush through the recorder, got %v", err)
+	}
+	if !w.Flushed {
+		t.Error("Expected the wrapped writer to be flushed")
+	}
+}
@@ -0,0 +1,123 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package server
+
+import (
+	"container/heap"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNonceUsed is returned when adding a nonce that is already stored
+	ErrNonceUsed = errors.New("nonce already used")
+	// ErrNonceCacheFull is returned when the client holds as many nonces
+	// that haven't expired yet as the cache allows
+	ErrNonceCacheFull = errors.New("nonce cache full")
+)
+
+// NonceCacheSize returns the number of nonces each client needs for
+// sending rate signed requests per second. Nonces are kept while their
+// timestamp is accepted, which is up to twice maxSkew (plus a second) for
+// timestamps ahead of the server clock.
+func NonceCacheSize(rate int, maxSkew time.Duration) int {
+	return rate * int((2*maxSkew + time.Second).Seconds())
+}
+
+// NonceCache remembers the signature nonces of each client until they
+// expire, holding at most size nonces per client. Nonces are never
+// forgotten before they expire, so a client with a full quota gets its new
+// nonces rejected, while the other clients are not affected.
+type NonceCache struct {
+	mu      sync.Mutex
+	size    int
+	expires map[nonceKey]time.Time
+	counts  map[string]int
+	queue   nonceQueue
+}
+
+// nonceKey identifies a nonce of a client
+type nonceKey struct {
+	client string
+	nonce  string
+}
+
+// NewNonceCache creates a NonceCache holding up to size nonces per client.
+func NewNonceCache(size int) *NonceCache {
+	return &NonceCache{
+		size:    size,
+		expires: make(map[nonceKey]time.Time),
+		counts:  make(map[string]int),
+	}
+}
+
+// Add stores the nonce of the client until expires. It returns
+// ErrNonceUsed when the nonce is already stored and not expired, and
+// ErrNonceCacheFull when there's no room left for the client.
+func (c *NonceCache) Add(client, nonce string, expires time.Time, now time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prune(now)
+	key := nonceKey{client: client, nonce: nonce}
+	if _, found := c.expires[key]; found {
+		return ErrNonceUsed
+	}
+	if c.counts[client] >= c.size {
+		return ErrNonceCacheFull
+	}
+	c.expires[key] = expires
+	c.counts[client]++
+	heap.Push(&c.queue, nonceEntry{key: key, expires: expires})
+	return nil
+}
+
+// Len returns the number of stored nonces.
+func (c *NonceCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.expires)
+}
+
+// prune removes the expired nonces, from the first to expire.
+func (c *NonceCache) prune(now time.Time) {
+	for len(c.queue) > 0 && !now.Before(c.queue[0].expires) {
+		entry := heap.Pop(&c.queue).(nonceEntry)
+		delete(c.expires, entry.key)
+		if c.counts[entry.key.client]--; c.counts[entry.key.client] == 0 {
+			delete(c.counts, entry.key.client)
+		}
+	}
+}
+
+// nonceEntry is a stored nonce with its expiration
+type nonceEntry struct {
+	key     nonceKey
+	expires time.Time
+}
+
+// nonceQueue is a min-heap of nonces ordered by expiration
+type nonceQueue []nonceEntry
+
+func (q nonceQueue) Len() int           { return len(q) }
+func (q nonceQueue) Less(i, j int) bool { return q[i].expires.Before(q[j].expires) }
+func (q nonceQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+func (q *nonceQueue) Push(x any) {
+	*q = append(*q, x.(nonceEntry))
+}
+
+func (q *nonceQueue) Pop() any {
+	old := *q
+	entry := old[len(old)-1]
+	old[len(old)-1] = nonceEntry{}
+	*q = old[:len(old)-1]
+	return entry
+}
@@ -0,0 +1,119 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package server
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNonceCache(t *testing.T) {
+	now := time.Now()
+	cache := NewNonceCache(2)
+	if err := cache.Add("", "a", now.Add(time.Minute), now); err != nil {
+		t.Fatalf("Expected new nonce to be added, got %v", err)
+	}
+	if err := cache.Add("", "a", now.Add(time.Minute), now); !errors.Is(err, ErrNonceUsed) {
+		t.Errorf("Expected duplicate nonce to be rejected, got %v", err)
+	}
+	if err := cache.Add("", "a", now.Add(2*time.Minute), now.Add(time.Minute)); err != nil {
+		t.Errorf("Expected expired nonce to be added again, got %v", err)
+	}
+}
+
+func TestNonceCacheFull(t *testing.T) {
+	now := time.Now()
+	cache := NewNonceCache(2)
+	for _, nonce := range []string{"a", "b"} {
+		if err := cache.Add("", nonce, now.Add(time.Minute), now); err != nil {
+			t.Fatalf("Expected nonce %s to be added, got %v", nonce, err)
+		}
+	}
+	if err := cache.Add("", "c", now.Add(time.Minute), now); !errors.Is(err, ErrNonceCacheFull) {
+		t.Errorf("Expected full cache error, got %v", err)
+	}
+	if err := cache.Add("", "a", now.Add(time.Minute), now); !errors.Is(err, ErrNonceUsed) {
+		t.Errorf("Expected unexpired nonce to be kept, got %v", err)
+	}
+	if got := cache.Len(); got != 2 {
+		t.Errorf("Expected 2 nonces, got %d", got)
+	}
+	if err := cache.Add("", "c", now.Add(2*time.Minute), now.Add(time.Minute)); err != nil {
+		t.Errorf("Expected nonce to be added once others expired, got %v", err)
+	}
+}
+
+func TestNonceCachePrune(t *testing.T) {
+	now := time.Now()
+	cache := NewNonceCache(10)
+	cache.Add("", "a", now.Add(time.Hour), now)
+	cache.Add("", "b", now.Add(time.Second), now)
+	cache.Add("", "c", now.Add(time.Second), now)
+	cache.Add("", "d", now.Add(time.Hour), now.Add(2*time.Second))
+	if got := cache.Len(); got != 2 {
+		t.Errorf("Expected nonces expired behind newer ones to be pruned, got %d nonces", got)
+	}
+}
+
+func TestNonceCacheClients(t *testing.T) {
+	now := time.Now()
+	cache := NewNonceCache(1)
+	if err := cache.Add("busy", "a", now.Add(time.Minute), now); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Add("busy", "b", now.Add(time.Minute), now); !errors.Is(err, ErrNonceCacheFull) {
+		t.Errorf("Expected full quota error, got %v", err)
+	}
+	if err := cache.Add("other", "b", now.Add(time.Minute), now); err != nil {
+		t.Errorf("Expected other client to not be affected, got %v", err)
+	}
+	if err := cache.Add("other", "a", now.Add(time.Minute), now); !errors.Is(err, ErrNonceCacheFull) {
+		t.Errorf("Expected nonces to be counted per client, got %v", err)
+	}
+}
+
+func TestNonceCacheDefaultSize(t *testing.T) {
+	const rate = 1000
+	maxSkew := 5 * time.Minute
+	size := NonceCacheSize(rate, maxSkew)
+	if size != 601000 {
+		t.Fatalf("Expected 601000 nonces, got %d", size)
+	}
+	cache := NewNonceCache(size)
+	start := time.Now()
+	// Sending rate requests per second with the current timestamp, each
+	// nonce is kept for maxSkew plus a second
+	accepted := 0
+	for i := 0; ; i++ {
+		now := start.Add(time.Duration(i) * time.Second / rate)
+		if err := cache.Add("", strconv.Itoa(i), now.Add(maxSkew+time.Second), now); err != nil {
+			t.Fatalf("Expected %d requests per second to be accepted, rejected after %d: %v", rate, accepted, err)
+		}
+		accepted++
+		if now.Sub(start) > 2*maxSkew {
+			break
+		}
+	}
+	// A burst of timestamps ahead of the server clock fills the quota
+	cache = NewNonceCache(size)
+	for i := 0; i < size; i++ {
+		if err := cache.Add("", strconv.Itoa(i), start.Add(2*maxSkew+time.Second), start); err != nil {
+			t.Fatalf("Expected nonce %d to be accepted, got %v", i, err)
+		}
+	}
+	if err := cache.Add("", "rejected", start.Add(2*maxSkew+time.Second), start); !errors.Is(err, ErrNonceCacheFull) {
+		t.Errorf("Expected nonce %d to be rejected, got %v", size+1, err)
+	}
+	if err := cache.Add("other", "accepted", start.Add(2*maxSkew+time.Second), start); err != nil {
+		t.Errorf("Expected other clients to not be affected, got %v", err)
+	}
+}
@@ -4,12 +4,24 @@ import (
 	"bytes"
 	"compress/gzip"
 	"crypto/ed25519"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"regexp"
 	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/xrdebug/xrdebug/internal/form"
+	"github.com/xrdebug/xrdebug/internal/metrics"
 )
 
 // createListener creates a TCP listener on the specified address and port.
@@ -57,42 +69,274 @@ func WithHeaders(next http.Handler) http.Handler {
 	})
 }
 
+// LimitBody is a middleware that limits the request body to maxBytes.
+// Reading past the limit fails with *http.MaxBytesError.
+func LimitBody(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Canonical returns the signed content for the given fields, which is the
+// concatenation of each key and its first value sorted by key.
+func Canonical(values url.Values) []byte {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var contents []byte
+	for _, k := range keys {
+		contents = append(contents, []byte(k+values.Get(k))...)
+	}
+	return contents
+}
+
+// signedContent returns the content covered by the request signature.
+// Batches (JSON arrays or NDJSON) are signed as the raw body, while single
+// requests are signed as the canonical form of their fields.
+func signedContent(r *http.Request) ([]byte, error) {
+	isBatch, err := form.IsBatch(r)
+	if err != nil {
+		return nil, err
+	}
+	if isBatch {
+		return form.Body(r)
+	}
+	if err := form.Parse(r); err != nil {
+		return nil, err
+	}
+	return Canonical(r.Form), nil
+}
+
+// Signature versions accepted in the X-Signature-Version header, requests
+// without the header use the legacy version of the existing clients
+const (
+	SignatureLegacy = "1"
+	SignatureV2     = "2"
+)
+
+// Keys looks up the public key of a signing client by the key id sent in
+// the X-Key-Id header, returning the client identity
+type Keys interface {
+	Lookup(id string) (string, ed25519.PublicKey, bool)
+}
+
+// KeySet holds the public keys trusted for verifying the signatures by key
+// id. The key id of a single key may be empty, as for the server own key.
+type KeySet map[string]ed25519.PublicKey
+
+// Lookup returns the key for the id sent in the X-Key-Id header and the
+// identity of the client, which is the key id. An empty id selects the only
+// key of a set with a single key.
+func (k KeySet) Lookup(id string) (string, ed25519.PublicKey, bool) {
+	if id == "" && len(k) == 1 {
+		for id, key := range k {
+			return id, key, true
+		}
+	}
+	key, found := k[id]
+	return id, key, found && id != ""
+}
+
+// KeyStore holds a KeySet that can be swapped while verifying requests,
+// such as when the trusted keys are reloaded.
+type KeyStore struct {
+	keys atomic.Pointer[KeySet]
+}
+
+// NewKeyStore creates a KeyStore holding keys.
+func NewKeyStore(keys KeySet) *KeyStore {
+	s := &KeyStore{}
+	s.Store(keys)
+	return s
+}
+
+// Store replaces the held keys.
+func (s *KeyStore) Store(keys KeySet) {
+	s.keys.Store(&keys)
+}
+
+// Lookup looks up the key in the held keys.
+func (s *KeyStore) Lookup(id string) (string, ed25519.PublicKey, bool) {
+	return (*s.keys.Load()).Lookup(id)
+}
+
+// SignatureConfig configures the replay protection and the versions
+// accepted by VerifySignature
+type SignatureConfig struct {
+	// MaxSkew is the maximum difference between the signature timestamp
+	// and the server clock
+	MaxSkew time.Duration
+	// Nonces remembers the nonces of the accepted signatures by client, nil
+	// disables the duplicate detection
+	Nonces *NonceCache
+	// Legacy accepts the legacy signature version, meant for migrating
+	// clients
+	Legacy bool
+}
+
+// nonceRegex matches the accepted signature nonces
+var nonceRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{16,128}$`)
+
+// CanonicalRequest returns the signed message of the version 2 signatures,
+// which binds the method, the request URI (path and query) and the body to
+// the signature timestamp and nonce:
+//
+//	xrdebug-v2
+//	METHOD
+//	/request/uri?query
+//	timestamp
+//	nonce
+//	hex(sha256(body))
+func CanonicalRequest(method, requestURI, timestamp, nonce string, body []byte) []byte {
+	hash := sha256.Sum256(body)
+	return []byte(strings.Join([]string{
+		"xrdebug-v2", method, requestURI, timestamp, nonce, hex.EncodeToString(hash[:]),
+	}, "\n"))
+}
+
+// signedMessage returns the version 2 message covered by the request
+// signature.
+func signedMessage(r *http.Request, timestamp, nonce string) ([]byte, error) {
+	body, err := form.Body(r)
+	if err != nil {
+		return nil, err
+	}
+	return CanonicalRequest(r.Method, r.URL.RequestURI(), timestamp, nonce, body), nil
+}
+
 // VerifySignature is a middleware that checks for the presence of a signature header in the request.
-func VerifySignature(publicKey ed25519.PublicKey) func(http.Handler) http.Handler {
+// Version 2 signatures (X-Signature-Version: 2) cover the CanonicalRequest
+// and carry the X-Signature-Timestamp (unix seconds) and X-Signature-Nonce
+// headers, timestamps outside the skew window and reused nonces are rejected.
+// Legacy signatures, accepted only when enabled, cover the canonical form
+// of the request fields, either url-encoded or a JSON object of scalar
+// fields, or the raw body for batches. They carry no timestamp nor nonce,
+// so they aren't protected against replays.
+// The key is selected by the X-Key-Id header, its id is the client identity
+// passed in the request context and reported in the access log.
+func VerifySignature(keys Keys, config SignatureConfig) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			signature := r.Header.Get("X-Signature")
 			if signature == "" {
-				http.Error(w, "Missing signature", http.StatusUnauthorized)
+				rejectSignature(w, r, "missing", "Missing signature", http.StatusUnauthorized)
 				return
 			}
-			if err := r.ParseForm(); err != nil {
-				http.Error(w, "Invalid form data", http.StatusBadRequest)
+			client, publicKey, found := keys.Lookup(r.Header.Get("X-Key-Id"))
+			if !found {
+				if r.Header.Get("X-Key-Id") == "" {
+					rejectSignature(w, r, "missing_key_id", "Missing key id", http.StatusUnauthorized)
+					return
+				}
+				rejectSignature(w, r, "unknown_key", "Unknown key id", http.StatusUnauthorized)
 				return
 			}
-			keys := make([]string, 0, len(r.Form))
-			for k := range r.Form {
-				keys = append(keys, k)
+			accept := func() {
+				if client != "" {
+					r = Identify(r, client)
+				}
+				next.ServeHTTP(w, r)
+			}
+			switch r.Header.Get("X-Signature-Version") {
+			case SignatureV2:
+			case "", SignatureLegacy:
+				if !config.Legacy {
+					rejectSignature(w, r, "legacy", "Legacy signature version not accepted", http.StatusUnauthorized)
+					return
+				}
+				content, err := signedContent(r)
+				if err != nil {
+					rejectForm(w, r, err)
+					return
+				}
+				if verify(w, r, publicKey, signature, content) {
+					accept()
+				}
+				return
+			default:
+				rejectSignature(w, r, "unsupported_version", "Unsupported signature version", http.StatusBadRequest)
+				return
 			}
-			sort.Strings(keys)
-			var contents []byte
-			for _, k := range keys {
-				contents = append(contents, []byte(k+r.Form.Get(k))...)
+			timestamp := r.Header.Get("X-Signature-Timestamp")
+			nonce := r.Header.Get("X-Signature-Nonce")
+			if timestamp == "" || nonce == "" {
+				rejectSignature(w, r, "missing_timestamp", "Missing signature timestamp or nonce", http.StatusUnauthorized)
+				return
 			}
-			sig, err := base64.StdEncoding.DecodeString(signature)
+			seconds, err := strconv.ParseInt(timestamp, 10, 64)
 			if err != nil {
-				http.Error(w, "Invalid signature format", http.StatusBadRequest)
+				rejectSignature(w, r, "invalid_timestamp", "Invalid signature timestamp", http.StatusBadRequest)
 				return
 			}
-			if !ed25519.Verify(publicKey, contents, sig) {
-				http.Error(w, "Invalid signature", http.StatusUnauthorized)
+			if !nonceRegex.MatchString(nonce) {
+				rejectSignature(w, r, "invalid_nonce", "Invalid signature nonce", http.StatusBadRequest)
 				return
 			}
-			next.ServeHTTP(w, r)
+			now := time.Now()
+			signedAt := time.Unix(seconds, 0)
+			if signedAt.Before(now.Add(-config.MaxSkew)) || signedAt.After(now.Add(config.MaxSkew)) {
+				rejectSignature(w, r, "stale_timestamp", "Signature timestamp out of range", http.StatusUnauthorized)
+				return
+			}
+			message, err := signedMessage(r, timestamp, nonce)
+			if err != nil {
+				rejectForm(w, r, err)
+				return
+			}
+			if !verify(w, r, publicKey, signature, message) {
+				return
+			}
+			// Nonces are remembered while their timestamp is accepted
+			expires := signedAt.Add(config.MaxSkew + time.Second)
+			if config.Nonces != nil {
+				if err := config.Nonces.Add(client, nonce, expires, now); errors.Is(err, ErrNonceCacheFull) {
+					rejectSignature(w, r, "nonce_cache_full", "Too many signed requests", http.StatusServiceUnavailable)
+					return
+				} else if err != nil {
+					rejectSignature(w, r, "replayed", "Signature nonce already used", http.StatusUnauthorized)
+					return
+				}
+			}
+			accept()
 		})
 	}
 }
 
+// verify checks the base64 encoded signature of message, writing the
+// signature error when it doesn't match.
+func verify(w http.ResponseWriter, r *http.Request, publicKey ed25519.PublicKey, signature string, message []byte) bool {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		rejectSignature(w, r, "invalid_format", "Invalid signature format", http.StatusBadRequest)
+		return false
+	}
+	if !ed25519.Verify(publicKey, message, sig) {
+		rejectSignature(w, r, "invalid", "Invalid signature", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// rejectForm writes the error of a request body that can't be read.
+func rejectForm(w http.ResponseWriter, r *http.Request, err error) {
+	Reject(r, err.Error())
+	metrics.SignatureFailures.Inc("invalid_form")
+	form.WriteError(w, err, http.StatusBadRequest, "Invalid form data")
+}
+
+// rejectSignature writes the signature error, recording it for the access
+// log and the metrics under the given reason.
+func rejectSignature(w http.ResponseWriter, r *http.Request, reason, message string, status int) {
+	Reject(r, message)
+	metrics.SignatureFailures.Inc(reason)
+	http.Error(w, message, status)
+}
+
 // validateTLSFiles checks if the provided TLS certificate and private key files
 // are valid and exist. Returns an error if the validation fails.
 func ValidateTLSFiles(certFile, keyFile string) error {
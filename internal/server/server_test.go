@@ -0,0 +1,470 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package server
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xrdebug/xrdebug/internal/identity"
+)
+
+func TestCanonical(t *testing.T) {
+	values := url.Values{
+		"topic": {"test"},
+		"body":  {"message"},
+		"id":    {"1", "2"},
+	}
+	expected := "bodymessageid1topictest"
+	if got := string(Canonical(values)); got != expected {
+		t.Errorf("Canonical() = %q, want %q", got, expected)
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(content string) string {
+		return base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, []byte(content)))
+	}
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		signature   string
+		status      int
+	}{
+		{
+			name:        "form",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "file_line=10&body=test",
+			signature:   sign("bodytestfile_line10"),
+			status:      http.StatusOK,
+		},
+		{
+			name:        "json",
+			contentType: "application/json",
+			body:        `{"file_line":10,"body":"test"}`,
+			signature:   sign("bodytestfile_line10"),
+			status:      http.StatusOK,
+		},
+		{
+			name:        "json batch",
+			contentType: "application/json",
+			body:        `[{"body":"a"},{"body":"b"}]`,
+			signature:   sign(`[{"body":"a"},{"body":"b"}]`),
+			status:      http.StatusOK,
+		},
+		{
+			name:        "ndjson batch",
+			contentType: "application/x-ndjson",
+			body:        "{\"body\":\"a\"}\n",
+			signature:   sign("{\"body\":\"a\"}\n"),
+			status:      http.StatusOK,
+		},
+		{
+			name:        "tampered batch",
+			contentType: "application/x-ndjson",
+			body:        "{\"body\":\"b\"}\n",
+			signature:   sign("{\"body\":\"a\"}\n"),
+			status:      http.StatusUnauthorized,
+		},
+		{
+			name:        "missing signature",
+			contentType: "application/json",
+			body:        `{"body":"test"}`,
+			status:      http.StatusUnauthorized,
+		},
+		{
+			name:        "invalid signature",
+			contentType: "application/json",
+			body:        `{"body":"tampered"}`,
+			signature:   sign("bodytest"),
+			status:      http.StatusUnauthorized,
+		},
+		{
+			name:        "invalid json",
+			contentType: "application/json",
+			body:        `{"body":`,
+			signature:   sign(""),
+			status:      http.StatusBadRequest,
+		},
+	}
+	handler := VerifySignature(KeySet{"": publicKey}, SignatureConfig{MaxSkew: time.Minute, Legacy: true})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.signature != "" {
+				req.Header.Set("X-Signature", tt.signature)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+// TestVerifySignatureLegacyClient signs a request the way the existing
+// clients do, serializing the sorted post fields without any other header.
+func TestVerifySignatureLegacyClient(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]string{
+		"body":      "My signed message",
+		"file_path": "file",
+		"file_line": "1",
+	}
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	serialized := ""
+	for _, key := range keys {
+		serialized += key + data[key]
+	}
+	signHeader := base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, []byte(serialized)))
+	body := "body=My+signed+message&file_path=file&file_line=1"
+	for _, legacy := range []bool{true, false} {
+		handler := VerifySignature(KeySet{"": publicKey}, SignatureConfig{MaxSkew: time.Minute, Legacy: legacy})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		req := httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Signature", signHeader)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		expected := http.StatusOK
+		if !legacy {
+			expected = http.StatusUnauthorized
+		}
+		if w.Code != expected {
+			t.Errorf("Expected status %d with legacy %v, got %d: %s", expected, legacy, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestCanonicalRequest(t *testing.T) {
+	expected := "xrdebug-v2\nPOST\n/messages?a=1\n1700000000\nnonce\n" +
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	if got := string(CanonicalRequest("POST", "/messages?a=1", "1700000000", "nonce", nil)); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestVerifySignatureVersion(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := "0123456789abcdef"
+	signV2 := func(method, uri, body string) string {
+		message := CanonicalRequest(method, uri, timestamp, nonce, []byte(body))
+		return base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, message))
+	}
+	legacy := base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, []byte("bodytest")))
+	tests := []struct {
+		name      string
+		method    string
+		target    string
+		body      string
+		version   string
+		signature string
+		legacy    bool
+		status    int
+	}{
+		{"v2 form", http.MethodPost, "/messages", "body=test", "2", signV2(http.MethodPost, "/messages", "body=test"), false, http.StatusOK},
+		{"v2 query", http.MethodGet, "/pauses/1?wait=1s", "", "2", signV2(http.MethodGet, "/pauses/1?wait=1s", ""), false, http.StatusOK},
+		{"v2 other method", http.MethodDelete, "/messages", "", "2", signV2(http.MethodPost, "/messages", ""), false, http.StatusUnauthorized},
+		{"v2 other path", http.MethodPost, "/messages", "body=test", "2", signV2(http.MethodPost, "/sessions/other/messages", "body=test"), false, http.StatusUnauthorized},
+		{"v2 other query", http.MethodGet, "/pauses/1?wait=1m", "", "2", signV2(http.MethodGet, "/pauses/1?wait=1s", ""), false, http.StatusUnauthorized},
+		{"v2 ambiguous fields", http.MethodPost, "/messages", "ab=c", "2", signV2(http.MethodPost, "/messages", "a=bc"), false, http.StatusUnauthorized},
+		{"v2 repeated values", http.MethodPost, "/messages", "id=1&id=2", "2", signV2(http.MethodPost, "/messages", "id=1&id=3"), false, http.StatusUnauthorized},
+		{"legacy rejected", http.MethodPost, "/messages", "body=test", "", legacy, false, http.StatusUnauthorized},
+		{"legacy explicit rejected", http.MethodPost, "/messages", "body=test", "1", legacy, false, http.StatusUnauthorized},
+		{"legacy accepted", http.MethodPost, "/messages", "body=test", "", legacy, true, http.StatusOK},
+		{"legacy explicit accepted", http.MethodPost, "/messages", "body=test", "1", legacy, true, http.StatusOK},
+		{"unsupported", http.MethodPost, "/messages", "body=test", "3", legacy, true, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := VerifySignature(KeySet{"": publicKey}, SignatureConfig{MaxSkew: time.Minute, Legacy: tt.legacy})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("X-Signature", tt.signature)
+			req.Header.Set("X-Signature-Timestamp", timestamp)
+			req.Header.Set("X-Signature-Nonce", nonce)
+			if tt.version != "" {
+				req.Header.Set("X-Signature-Version", tt.version)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestVerifySignatureBody(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := "0123456789abcdef"
+	body := "body=test&topic=a"
+	message := CanonicalRequest(http.MethodPost, "/messages", timestamp, nonce, []byte(body))
+	var got string
+	handler := VerifySignature(KeySet{"": publicKey}, SignatureConfig{MaxSkew: time.Minute})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		got = r.Form.Get("topic")
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Signature", base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, message)))
+	req.Header.Set("X-Signature-Version", SignatureV2)
+	req.Header.Set("X-Signature-Timestamp", timestamp)
+	req.Header.Set("X-Signature-Nonce", nonce)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if got != "a" {
+		t.Errorf("Expected the body to be readable after verification, got topic %q", got)
+	}
+}
+
+func TestVerifySignatureKeyID(t *testing.T) {
+	laptopPublic, laptopPrivate, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciPublic, ciPrivate, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		keys     KeySet
+		keyID    string
+		private  ed25519.PrivateKey
+		status   int
+		expected string
+	}{
+		{"selected key", KeySet{"laptop": laptopPublic, "ci": ciPublic}, "ci", ciPrivate, http.StatusOK, "ci"},
+		{"other key", KeySet{"laptop": laptopPublic, "ci": ciPublic}, "ci", laptopPrivate, http.StatusUnauthorized, ""},
+		{"unknown key", KeySet{"laptop": laptopPublic, "ci": ciPublic}, "server", laptopPrivate, http.StatusUnauthorized, ""},
+		{"missing key id", KeySet{"laptop": laptopPublic, "ci": ciPublic}, "", laptopPrivate, http.StatusUnauthorized, ""},
+		{"single key", KeySet{"laptop": laptopPublic}, "", laptopPrivate, http.StatusOK, "laptop"},
+		{"server key", KeySet{"": laptopPublic}, "", laptopPrivate, http.StatusOK, ""},
+		{"server key with id", KeySet{"": laptopPublic}, "laptop", laptopPrivate, http.StatusUnauthorized, ""},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := VerifySignature(tt.keys, SignatureConfig{MaxSkew: time.Minute})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = identity.From(r.Context())
+			}))
+			timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+			nonce := fmt.Sprintf("nonce-%010d", i)
+			message := CanonicalRequest(http.MethodPost, "/messages", timestamp, nonce, []byte("body=test"))
+			req := httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader("body=test"))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("X-Signature", base64.StdEncoding.EncodeToString(ed25519.Sign(tt.private, message)))
+			req.Header.Set("X-Signature-Version", SignatureV2)
+			req.Header.Set("X-Signature-Timestamp", timestamp)
+			req.Header.Set("X-Signature-Nonce", nonce)
+			if tt.keyID != "" {
+				req.Header.Set("X-Key-Id", tt.keyID)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if got != tt.expected {
+				t.Errorf("Expected client %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestKeyStore(t *testing.T) {
+	first, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewKeyStore(KeySet{"laptop": first})
+	if _, key, found := store.Lookup("laptop"); !found || !key.Equal(first) {
+		t.Error("Expected the first key")
+	}
+	store.Store(KeySet{"ci": second})
+	if _, _, found := store.Lookup("laptop"); found {
+		t.Error("Expected the replaced key to be gone")
+	}
+	if id, key, found := store.Lookup(""); !found || id != "ci" || !key.Equal(second) {
+		t.Errorf("Expected the second key, got %q", id)
+	}
+}
+
+func TestVerifySignatureReplay(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Unix()
+	tests := []struct {
+		name      string
+		timestamp string
+		nonce     string
+		status    int
+		reason    string
+	}{
+		{"accepted", strconv.FormatInt(now, 10), "nonce-0000000001", http.StatusOK, ""},
+		{"replayed", strconv.FormatInt(now, 10), "nonce-0000000001", http.StatusUnauthorized, "Signature nonce already used"},
+		{"new nonce", strconv.FormatInt(now, 10), "nonce-0000000002", http.StatusOK, ""},
+		{"within skew", strconv.FormatInt(now-50, 10), "nonce-0000000003", http.StatusOK, ""},
+		{"stale", strconv.FormatInt(now-120, 10), "nonce-0000000004", http.StatusUnauthorized, "Signature timestamp out of range"},
+		{"future", strconv.FormatInt(now+120, 10), "nonce-0000000005", http.StatusUnauthorized, "Signature timestamp out of range"},
+		{"missing timestamp", "", "nonce-0000000006", http.StatusUnauthorized, "Missing signature timestamp or nonce"},
+		{"missing nonce", strconv.FormatInt(now, 10), "", http.StatusUnauthorized, "Missing signature timestamp or nonce"},
+		{"invalid timestamp", "now", "nonce-0000000007", http.StatusBadRequest, "Invalid signature timestamp"},
+		{"invalid nonce", strconv.FormatInt(now, 10), "short", http.StatusBadRequest, "Invalid signature nonce"},
+	}
+	handler := VerifySignature(KeySet{"": publicKey}, SignatureConfig{
+		MaxSkew: time.Minute,
+		Nonces:  NewNonceCache(10),
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := "body=test"
+			message := CanonicalRequest(http.MethodPost, "/messages", tt.timestamp, tt.nonce, []byte(body))
+			req := httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("X-Signature", base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, message)))
+			req.Header.Set("X-Signature-Version", SignatureV2)
+			req.Header.Set("X-Signature-Timestamp", tt.timestamp)
+			req.Header.Set("X-Signature-Nonce", tt.nonce)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if got := strings.TrimSpace(w.Body.String()); tt.reason != "" && got != tt.reason {
+				t.Errorf("Expected reason %q, got %q", tt.reason, got)
+			}
+		})
+	}
+}
+
+func TestVerifySignatureNonceCacheFull(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := VerifySignature(KeySet{"": publicKey}, SignatureConfig{
+		MaxSkew: time.Minute,
+		Nonces:  NewNonceCache(1),
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	tests := []struct {
+		nonce  string
+		status int
+	}{
+		{"nonce-0000000001", http.StatusOK},
+		{"nonce-0000000002", http.StatusServiceUnavailable},
+		{"nonce-0000000001", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		message := CanonicalRequest(http.MethodPost, "/messages", timestamp, tt.nonce, nil)
+		req := httptest.NewRequest(http.MethodPost, "/messages", nil)
+		req.Header.Set("X-Signature", base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, message)))
+		req.Header.Set("X-Signature-Version", SignatureV2)
+		req.Header.Set("X-Signature-Timestamp", timestamp)
+		req.Header.Set("X-Signature-Nonce", tt.nonce)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Errorf("Expected status %d for %s, got %d: %s", tt.status, tt.nonce, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestVerifySignatureNonceAfterVerify(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonces := NewNonceCache(10)
+	handler := VerifySignature(KeySet{"": publicKey}, SignatureConfig{MaxSkew: time.Minute, Nonces: nonces})(http.NotFoundHandler())
+	req := httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader("body=test"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Signature", base64.StdEncoding.EncodeToString(make([]byte, ed25519.SignatureSize)))
+	req.Header.Set("X-Signature-Version", SignatureV2)
+	req.Header.Set("X-Signature-Timestamp", strconv.FormatInt(time.Now().Unix(), 10))
+	req.Header.Set("X-Signature-Nonce", "nonce-0000000001")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if nonces.Len() != 0 {
+		t.Errorf("Expected invalid signatures to not store the nonce, got %d nonces", nonces.Len())
+	}
+}
+
+func TestLimitBody(t *testing.T) {
+	handler := LimitBody(8)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{"body=a", http.StatusOK},
+		{"body=too+long", http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Errorf("%q: expected status %d, got %d", tt.body, tt.status, w.Code)
+		}
+	}
+}
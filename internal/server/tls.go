@@ -0,0 +1,50 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sync/atomic"
+)
+
+// Certificate holds the TLS certificate served by the server, which can be
+// reloaded from its files without restarting the listener.
+type Certificate struct {
+	certFile string
+	keyFile  string
+	value    atomic.Pointer[tls.Certificate]
+}
+
+// LoadCertificate loads the TLS certificate and private key files.
+func LoadCertificate(certFile, keyFile string) (*Certificate, error) {
+	c := &Certificate{certFile: certFile, keyFile: keyFile}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Reload loads the certificate files again, keeping the current
+// certificate when they are invalid.
+func (c *Certificate) Reload() error {
+	certificate, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	c.value.Store(&certificate)
+	return nil
+}
+
+// GetCertificate returns the current certificate, meant for
+// tls.Config.GetCertificate.
+func (c *Certificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return c.value.Load(), nil
+}
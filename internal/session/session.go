@@ -0,0 +1,328 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+// Package session provides isolated debug sessions, each one with its own
+// messages, stream clients, history and pause locks.
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/xrdebug/xrdebug/internal/cipher"
+	"github.com/xrdebug/xrdebug/internal/controller/sse"
+	"github.com/xrdebug/xrdebug/internal/dump"
+	"github.com/xrdebug/xrdebug/internal/history"
+	"github.com/xrdebug/xrdebug/internal/metrics"
+	"github.com/xrdebug/xrdebug/internal/pausectl"
+)
+
+// messagesBuffer is the capacity of each session messages channel
+const messagesBuffer = 100
+
+// drainInterval is the interval for checking the messages channels on shutdown
+const drainInterval = 10 * time.Millisecond
+
+var (
+	ErrInvalidName = errors.New("invalid session name")
+	ErrLimit       = errors.New("session limit reached")
+	ErrNotFound    = errors.New("session not found")
+)
+
+// namePattern restricts the names accepted for new sessions
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// Session represents an isolated debug session
+type Session struct {
+	// Name identifies the session
+	Name string
+	// Messages receives the messages to broadcast
+	Messages chan string
+	// Clients holds the connected stream clients
+	Clients map[*sse.Client]bool
+	// ClientsMu guards Clients
+	ClientsMu *sync.Mutex
+	// History keeps the messages for replay
+	History *history.Store
+	// Locks manages the session pause locks
+	Locks *pausectl.Manager
+	// Page is the gzipped user interface for the session
+	Page []byte
+	// active is the number of requests being served, guarded by the
+	// Registry lock
+	active int
+	// lastUsed is the end of the last request, guarded by the Registry lock
+	lastUsed time.Time
+}
+
+// Config holds the settings shared by all the sessions
+type Config struct {
+	// HistorySize is the maximum number of messages kept for replay
+	HistorySize int
+	// HistoryAge is the maximum age of the messages kept for replay
+	HistoryAge time.Duration
+	// Queue configures the per-client event queues
+	Queue sse.Queue
+	// SymmetricKey encrypts the stream when holding a key
+	SymmetricKey *cipher.Key
+	// LockExpiration is the expiration of the pause locks
+	LockExpiration time.Duration
+	// LockCleanup is the interval for removing expired pause locks
+	LockCleanup time.Duration
+	// MaxSessions limits the number of sessions, zero means no limit
+	MaxSessions int
+	// Page builds the gzipped user interface for a session name
+	Page func(name string) ([]byte, error)
+}
+
+// Registry creates and holds the sessions by name
+type Registry struct {
+	mu          sync.Mutex
+	sessions    map[string]*Session
+	config      Config
+	defaultName string
+	closed      bool
+}
+
+// NewRegistry creates a Registry with its default session already started.
+// The default session name is not subject to name validation.
+func NewRegistry(config Config, defaultName string) (*Registry, error) {
+	r := &Registry{
+		sessions:    make(map[string]*Session),
+		config:      config,
+		defaultName: defaultName,
+	}
+	if _, err := r.create(defaultName); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Default returns the session used by the unprefixed routes
+func (r *Registry) Default() *Session {
+	s, _ := r.Get(r.defaultName)
+	return s
+}
+
+// Get returns the session for the given name, or ErrNotFound when it
+// doesn't exist.
+func (r *Registry) Get(name string) (*Session, error) {
+	r.mu.Lock()
+	s, found := r.sessions[name]
+	r.mu.Unlock()
+	if found {
+		return s, nil
+	}
+	if !namePattern.MatchString(name) {
+		return nil, ErrInvalidName
+	}
+	return nil, ErrNotFound
+}
+
+// Create returns the session for the given name, creating it when needed.
+func (r *Registry) Create(name string) (*Session, error) {
+	if s, err := r.Get(name); !errors.Is(err, ErrNotFound) {
+		return s, err
+	}
+	return r.create(name)
+}
+
+// RemoveIdle removes the sessions other than the default one that have
+// been unused for ttl, stopping their dispatcher and pause locks. Sessions
+// serving requests, with stream clients, pause locks or pending messages
+// are kept. It returns the names of the removed sessions.
+func (r *Registry) RemoveIdle(ttl time.Duration) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	var removed []string
+	for name, s := range r.sessions {
+		if name == r.defaultName || s.active > 0 || time.Since(s.lastUsed) < ttl ||
+			len(s.Messages) > 0 || len(s.Locks.IDs()) > 0 {
+			continue
+		}
+		s.ClientsMu.Lock()
+		clients := len(s.Clients)
+		s.ClientsMu.Unlock()
+		if clients > 0 {
+			continue
+		}
+		delete(r.sessions, name)
+		close(s.Messages)
+		s.Locks.Close()
+		removed = append(removed, name)
+	}
+	return removed
+}
+
+// Each calls fn for every session
+func (r *Registry) Each(fn func(*Session)) {
+	r.mu.Lock()
+	sessions := make([]*Session, 0, len(r.sessions))
+	for _, s := range r.sessions {
+		sessions = append(sessions, s)
+	}
+	r.mu.Unlock()
+	for _, s := range sessions {
+		fn(s)
+	}
+}
+
+// Handle returns an http.Handler that resolves the session from the `name`
+// path value, falling back to the default session for unprefixed routes.
+// Unknown sessions are not found.
+func (r *Registry) Handle(handler func(*Session) http.Handler) http.Handler {
+	return r.handle(r.Get, handler)
+}
+
+// HandleCreate returns an http.Handler like Handle, creating the session
+// when needed.
+func (r *Registry) HandleCreate(handler func(*Session) http.Handler) http.Handler {
+	return r.handle(r.Create, handler)
+}
+
+// handle resolves the session with get, tracking the request so the
+// session isn't removed while serving it.
+func (r *Registry) handle(get func(name string) (*Session, error), handler func(*Session) http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		name := req.PathValue("name")
+		if name == "" {
+			name = r.defaultName
+		}
+		s, err := r.acquire(name, get)
+		switch {
+		case errors.Is(err, ErrLimit):
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		case errors.Is(err, ErrNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer r.release(s)
+		handler(s).ServeHTTP(w, req)
+	})
+}
+
+// acquire resolves the session with get, marking it as serving a request.
+// It retries when the session is removed in between.
+func (r *Registry) acquire(name string, get func(name string) (*Session, error)) (*Session, error) {
+	for {
+		s, err := get(name)
+		if err != nil {
+			return nil, err
+		}
+		r.mu.Lock()
+		if r.sessions[name] == s {
+			s.active++
+			r.mu.Unlock()
+			return s, nil
+		}
+		r.mu.Unlock()
+	}
+}
+
+// release marks the end of a request served by the session.
+func (r *Registry) release(s *Session) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s.active--
+	s.lastUsed = time.Now()
+}
+
+// Shutdown prepares every session for the server shutdown. It deletes the
+// outstanding pause locks, reporting each one to release, broadcasts the
+// shutdown event which ends the streams, and waits until the messages
+// channels are drained or ctx is done.
+func (r *Registry) Shutdown(ctx context.Context, release func(s *Session, id string)) error {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+	var sessions []*Session
+	r.Each(func(s *Session) {
+		sessions = append(sessions, s)
+	})
+	for _, s := range sessions {
+		for _, id := range s.Locks.IDs() {
+			s.Locks.Delete(id)
+			release(s, id)
+			if err := s.send(ctx, sse.EventPauseContinue, id); err != nil {
+				return err
+			}
+		}
+		if err := s.send(ctx, sse.EventShutdown, ""); err != nil {
+			return err
+		}
+	}
+	ticker := time.NewTicker(drainInterval)
+	defer ticker.Stop()
+	for _, s := range sessions {
+		for len(s.Messages) > 0 {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return nil
+}
+
+// send broadcasts an action message to the session, unless ctx is done first.
+func (s *Session) send(ctx context.Context, action, id string) error {
+	jsonMsg, _ := json.Marshal(dump.New(action, "", "", "", "", "", id))
+	select {
+	case s.Messages <- string(jsonMsg):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// create builds a session and starts its dispatcher
+func (r *Registry) create(name string) (*Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, found := r.sessions[name]; found {
+		return s, nil
+	}
+	if r.config.MaxSessions > 0 && len(r.sessions) >= r.config.MaxSessions {
+		return nil, ErrLimit
+	}
+	page, err := r.config.Page(name)
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{
+		Name:      name,
+		Messages:  make(chan string, messagesBuffer),
+		Clients:   make(map[*sse.Client]bool),
+		ClientsMu: &sync.Mutex{},
+		History:   history.New(r.config.HistorySize, r.config.HistoryAge),
+		Locks:     pausectl.NewManager(r.config.LockExpiration, r.config.LockCleanup),
+		Page:      page,
+		lastUsed:  time.Now(),
+	}
+	s.Locks.OnExpired(func(id string) {
+		metrics.Pauses.Inc("expired")
+		s.send(context.Background(), sse.EventPauseExpired, id)
+	})
+	sse.StartDispatcher(s.Messages, s.Clients, s.ClientsMu, r.config.SymmetricKey, s.History, r.config.Queue)
+	r.sessions[name] = s
+	return s, nil
+}
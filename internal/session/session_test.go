@@ -0,0 +1,245 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package session
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/xrdebug/xrdebug/internal/controller/sse"
+)
+
+func testConfig(maxSessions int) Config {
+	return Config{
+		HistorySize:    10,
+		Queue:          sse.Queue{Size: 10, Policy: sse.PolicyDropOldest, Stats: &sse.Stats{}},
+		LockExpiration: time.Minute,
+		LockCleanup:    time.Minute,
+		MaxSessions:    maxSessions,
+		Page: func(name string) ([]byte, error) {
+			return []byte(name), nil
+		},
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry, err := NewRegistry(testConfig(3), "My Session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("default session", func(t *testing.T) {
+		s := registry.Default()
+		if s == nil || s.Name != "My Session" {
+			t.Fatalf("Expected default session, got %v", s)
+		}
+		if string(s.Page) != "My Session" {
+			t.Errorf("Expected page for default session, got %q", s.Page)
+		}
+	})
+	t.Run("get unknown", func(t *testing.T) {
+		if _, err := registry.Get("team-a"); err != ErrNotFound {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	})
+	t.Run("create once", func(t *testing.T) {
+		a, err := registry.Create("team-a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, _ := registry.Get("team-a")
+		if a != again {
+			t.Error("Expected the same session")
+		}
+		if a == registry.Default() {
+			t.Error("Expected an isolated session")
+		}
+	})
+	t.Run("invalid name", func(t *testing.T) {
+		if _, err := registry.Create("../etc"); err != ErrInvalidName {
+			t.Errorf("Expected ErrInvalidName, got %v", err)
+		}
+	})
+	t.Run("limit", func(t *testing.T) {
+		if _, err := registry.Create("team-b"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := registry.Create("team-c"); err != ErrLimit {
+			t.Errorf("Expected ErrLimit, got %v", err)
+		}
+	})
+	t.Run("each", func(t *testing.T) {
+		count := 0
+		registry.Each(func(*Session) { count++ })
+		if count != 3 {
+			t.Errorf("Expected 3 sessions, got %d", count)
+		}
+	})
+}
+
+func TestSessionIsolation(t *testing.T) {
+	registry, err := NewRegistry(testConfig(0), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := registry.Create("a")
+	b, _ := registry.Create("b")
+	a.Messages <- `{"action":"message"}`
+	deadline := time.Now().Add(time.Second)
+	for a.History.Len() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if a.History.Len() != 1 {
+		t.Errorf("Expected 1 entry in session a, got %d", a.History.Len())
+	}
+	if b.History.Len() != 0 {
+		t.Errorf("Expected no entries in session b, got %d", b.History.Len())
+	}
+	if _, err := a.Locks.New("lock"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Locks.Get("lock"); err == nil {
+		t.Error("Expected lock to be isolated")
+	}
+}
+
+func TestRegistryHandle(t *testing.T) {
+	registry, err := NewRegistry(testConfig(2), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(s *Session) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(s.Name))
+		})
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /stream", registry.Handle(write))
+	mux.Handle("GET /sessions/{name}/stream", registry.Handle(write))
+	mux.Handle("POST /sessions/{name}/messages", registry.HandleCreate(write))
+	tests := []struct {
+		method   string
+		path     string
+		status   int
+		expected string
+	}{
+		{http.MethodGet, "/stream", http.StatusOK, "default"},
+		{http.MethodGet, "/sessions/team/stream", http.StatusNotFound, ""},
+		{http.MethodPost, "/sessions/team/messages", http.StatusOK, "team"},
+		{http.MethodGet, "/sessions/team/stream", http.StatusOK, "team"},
+		{http.MethodGet, "/sessions/team..%20x/stream", http.StatusBadRequest, ""},
+		{http.MethodPost, "/sessions/team..%20x/messages", http.StatusBadRequest, ""},
+		{http.MethodPost, "/sessions/other/messages", http.StatusServiceUnavailable, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.path, tt.status, w.Code)
+		}
+		if tt.expected != "" && w.Body.String() != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.path, tt.expected, w.Body.String())
+		}
+	}
+}
+
+func TestRegistryRemoveIdle(t *testing.T) {
+	registry, err := NewRegistry(testConfig(0), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idle, _ := registry.Create("idle")
+	locked, _ := registry.Create("locked")
+	if _, err := locked.Locks.New("lock"); err != nil {
+		t.Fatal(err)
+	}
+	if removed := registry.RemoveIdle(time.Hour); len(removed) != 0 {
+		t.Errorf("Expected no sessions removed before the ttl, got %v", removed)
+	}
+	removed := registry.RemoveIdle(0)
+	if len(removed) != 1 || removed[0] != "idle" {
+		t.Fatalf("Expected idle session removed, got %v", removed)
+	}
+	if _, err := registry.Get("idle"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if _, ok := <-idle.Messages; ok {
+		t.Error("Expected the removed session messages closed")
+	}
+	if _, err := registry.Get("locked"); err != nil {
+		t.Errorf("Expected the session with a lock kept, got %v", err)
+	}
+	if _, err := registry.Get("default"); err != nil {
+		t.Errorf("Expected the default session kept, got %v", err)
+	}
+	again, _ := registry.Create("idle")
+	if again == idle {
+		t.Error("Expected a new session after the removal")
+	}
+}
+
+func TestRegistryShutdown(t *testing.T) {
+	registry, err := NewRegistry(testConfig(0), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := registry.Default()
+	if _, err := s.Locks.New("lock"); err != nil {
+		t.Fatal(err)
+	}
+	var released []string
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = registry.Shutdown(ctx, func(s *Session, id string) {
+		released = append(released, s.Name+"/"+id)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(released) != 1 || released[0] != "default/lock" {
+		t.Errorf("Expected released lock default/lock, got %v", released)
+	}
+	if _, err := s.Locks.Get("lock"); err == nil {
+		t.Error("Expected lock to be deleted")
+	}
+	deadline := time.Now().Add(time.Second)
+	for s.History.Len() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	entries := s.History.Since(0)
+	if len(entries) != 2 || entries[0].Event != sse.EventPauseContinue || entries[1].Event != sse.EventShutdown {
+		t.Errorf("Expected pause-continue and shutdown events, got %v", entries)
+	}
+}
+
+func TestSessionPauseExpired(t *testing.T) {
+	config := testConfig(0)
+	config.LockExpiration = 20 * time.Millisecond
+	config.LockCleanup = 10 * time.Millisecond
+	registry, err := NewRegistry(config, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := registry.Default()
+	if _, err := s.Locks.New("lock"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for s.History.Len() < 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	entries := s.History.Since(0)
+	if len(entries) != 1 || entries[0].Event != sse.EventPauseExpired {
+		t.Errorf("Expected pause-expired event, got %v", entries)
+	}
+}
@@ -0,0 +1,124 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package main
+
+import (
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/xrdebug/xrdebug/internal/cipher"
+	"github.com/xrdebug/xrdebug/internal/cli"
+)
+
+// KeygenOptions holds the options of the keygen command
+type KeygenOptions struct {
+	// SignPrivateKey is the path to write the ed25519 private key
+	SignPrivateKey string
+	// SymmetricKey is the path to write the symmetric key
+	SymmetricKey string
+	// Force overwrites existing key files
+	Force bool
+}
+
+// keygen generates the keys for sign verification and encryption, writing
+// them to files readable only by the owner. The public key of the signing
+// key is written next to it with the `.pub` extension. Every target is
+// checked before writing any key, so a failure leaves no partial set.
+func keygen(args []string, stdout io.Writer) error {
+	var options KeygenOptions
+	set := flag.NewFlagSet("keygen", flag.ExitOnError)
+	if err := cli.Parse(set, keygenFlags, args, &options); err != nil {
+		return err
+	}
+	if options.SignPrivateKey == "" && options.SymmetricKey == "" {
+		return fmt.Errorf("nothing to generate, provide -x or -k")
+	}
+	targets, err := keygenTargets(options)
+	if err != nil {
+		return err
+	}
+	for _, path := range targets {
+		if err := cipher.CheckKeyFile(path, options.Force); err != nil {
+			return err
+		}
+	}
+	var serveArgs string
+	if options.SignPrivateKey != "" {
+		privateKey, err := cipher.LoadPrivateKey("")
+		if err != nil {
+			return err
+		}
+		pemKey, err := cipher.PemKey(privateKey)
+		if err != nil {
+			return err
+		}
+		if err := cipher.WriteKeyFile(options.SignPrivateKey, []byte(pemKey), options.Force); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Private key written to %s\n", options.SignPrivateKey)
+		pemPublicKey, err := cipher.PemPublicKey(privateKey.Public().(ed25519.PublicKey))
+		if err != nil {
+			return err
+		}
+		publicKeyPath := publicKeyFile(options.SignPrivateKey)
+		if err := cipher.WriteKeyFile(publicKeyPath, []byte(pemPublicKey), options.Force); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Public key written to %s (for the server -trusted-keys)\n", publicKeyPath)
+		serveArgs += " -s -x " + options.SignPrivateKey
+	}
+	if options.SymmetricKey != "" {
+		symmetricKey, err := cipher.LoadSymmetricKey("")
+		if err != nil {
+			return err
+		}
+		if err := cipher.WriteKeyFile(options.SymmetricKey, []byte(cipher.Base64(symmetricKey)), options.Force); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Symmetric key written to %s\n", options.SymmetricKey)
+		serveArgs += " -e -k " + options.SymmetricKey
+	}
+	fmt.Fprintf(stdout, "Run the server with: xrdebug serve%s\n", serveArgs)
+	return nil
+}
+
+// publicKeyFile returns the path of the public key for the private key path,
+// replacing its extension with `.pub`.
+func publicKeyFile(privateKeyPath string) string {
+	return strings.TrimSuffix(privateKeyPath, filepath.Ext(privateKeyPath)) + ".pub"
+}
+
+// keygenTargets returns the paths written by keygen, failing when a path
+// would be written twice.
+func keygenTargets(options KeygenOptions) ([]string, error) {
+	var targets []string
+	if options.SignPrivateKey != "" {
+		if filepath.Ext(options.SignPrivateKey) == ".pub" {
+			return nil, fmt.Errorf("private key path %s must not end in .pub", options.SignPrivateKey)
+		}
+		targets = append(targets, options.SignPrivateKey, publicKeyFile(options.SignPrivateKey))
+	}
+	if options.SymmetricKey != "" {
+		targets = append(targets, options.SymmetricKey)
+	}
+	seen := make(map[string]bool)
+	for _, path := range targets {
+		clean := filepath.Clean(path)
+		if seen[clean] {
+			return nil, fmt.Errorf("key path %s is used more than once", path)
+		}
+		seen[clean] = true
+	}
+	return targets, nil
+}
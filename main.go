@@ -12,16 +12,22 @@
 package main
 
 import (
+	"context"
 	"crypto/ed25519"
+	"crypto/tls"
 	"embed"
+	"flag"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
-	"sync"
+	"syscall"
 	"time"
 
+	"github.com/xrdebug/xrdebug/internal/auth"
 	"github.com/xrdebug/xrdebug/internal/build"
 	"github.com/xrdebug/xrdebug/internal/cipher"
 	"github.com/xrdebug/xrdebug/internal/cli"
@@ -29,18 +35,19 @@ import (
 	"github.com/xrdebug/xrdebug/internal/controller/pause"
 	"github.com/xrdebug/xrdebug/internal/controller/spa"
 	"github.com/xrdebug/xrdebug/internal/controller/sse"
-	"github.com/xrdebug/xrdebug/internal/pausectl"
+	"github.com/xrdebug/xrdebug/internal/logfile"
+	"github.com/xrdebug/xrdebug/internal/metrics"
+	"github.com/xrdebug/xrdebug/internal/reload"
 	"github.com/xrdebug/xrdebug/internal/server"
+	"github.com/xrdebug/xrdebug/internal/session"
 )
 
 //go:embed web/* assets/*
 var filesystem embed.FS
 
 type ServerDeps struct {
-	Logger    cli.Logger
-	Messages  chan string
-	Clients   map[*sse.Client]bool
-	ClientsMu *sync.Mutex
+	Logger     cli.Logger
+	QueueStats *sse.Stats
 }
 
 func middleware(handler http.Handler, middlewares ...func(http.Handler) http.Handler) http.Handler {
@@ -51,23 +58,51 @@ func middleware(handler http.Handler, middlewares ...func(http.Handler) http.Han
 }
 
 func main() {
-	var clientsMu sync.Mutex
 	deps := &ServerDeps{
-		Logger:    cli.NewLogger(),
-		Messages:  make(chan string, 100),
-		Clients:   make(map[*sse.Client]bool),
-		ClientsMu: &clientsMu,
+		QueueStats: &sse.Stats{},
 	}
-	if err := run(deps); err != nil {
+	command, args := splitCommand(os.Args[1:])
+	var err error
+	switch command {
+	case "serve":
+		err = run(deps, args)
+	case "keygen":
+		err = keygen(args, os.Stdout)
+	case "send":
+		err = send(args, stdinBody(), os.Stdout)
+	case "follow":
+		err = followCommand(args, os.Stdin, os.Stdout)
+	default:
+		err = fmt.Errorf("unknown command `%s`, expected serve, keygen, send or follow", command)
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
 }
 
+// splitCommand returns the command and its arguments, the serve command
+// is used when the first argument is a flag.
+func splitCommand(args []string) (string, []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return "serve", args
+	}
+	return args[0], args[1:]
+}
+
+// stdinBody returns the standard input when it is piped or redirected.
+func stdinBody() io.Reader {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice != 0 {
+		return nil
+	}
+	return os.Stdin
+}
+
 // run initializes and starts the xrDebug server. It sets up HTTP routes,
 // initializes message channels, and manages client connections.
-func run(deps *ServerDeps) error {
-	options, err := cli.NewOptions(flags)
+func run(deps *ServerDeps, args []string) error {
+	options, err := cli.ParseOptions(flag.NewFlagSet("serve", flag.ExitOnError), flags, args)
 	if err != nil {
 		return err
 	}
@@ -75,21 +110,84 @@ func run(deps *ServerDeps) error {
 		fmt.Printf("%s %s\n", name, version)
 		os.Exit(0)
 	}
+	if options.PrintConfig {
+		return cli.WriteConfig(os.Stdout, flags, options)
+	}
+	if deps.Logger == nil {
+		deps.Logger, err = cli.NewStructuredLogger(os.Stderr, options.LogFormat, options.LogLevel)
+		if err != nil {
+			return err
+		}
+	}
+	accessLogger := deps.Logger
+	if options.AccessLog != "" {
+		if options.AccessLogMaxSize < 0 || options.AccessLogBackups < 0 {
+			return fmt.Errorf("access log size and backups must not be negative")
+		}
+		file, err := logfile.Open(options.AccessLog, int64(options.AccessLogMaxSize)<<20, options.AccessLogBackups)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		accessLogger, err = cli.NewStructuredLogger(file, options.LogFormat, options.LogLevel)
+		if err != nil {
+			return err
+		}
+	}
 	if err := validateEditor(options.Editor); err != nil {
 		return err
 	}
+	if options.PauseTTL <= 0 || options.PauseCleanup <= 0 {
+		return fmt.Errorf("pause ttl and cleanup must be greater than 0")
+	}
+	if options.SessionIdleTTL < 0 {
+		return fmt.Errorf("session idle ttl must not be negative")
+	}
+	if options.SignMaxSkew <= 0 {
+		return fmt.Errorf("sign max skew must be greater than 0")
+	}
+	if options.SignNonceCache < 0 {
+		return fmt.Errorf("sign nonce cache must not be negative")
+	}
+	if options.MaxBodySize < 1 {
+		return fmt.Errorf("max body size must be greater than 0")
+	}
+	queuePolicy, err := sse.ParsePolicy(options.QueuePolicy)
+	if err != nil {
+		return err
+	}
 	if err := server.ValidateTLSFiles(options.TLSCert, options.TLSPrivateKey); err != nil {
 		return err
 	}
+	if options.UIToken != "" && options.UISessionTTL <= 0 {
+		return fmt.Errorf("ui session ttl must be greater than 0")
+	}
+	if options.ReloadInterval < 0 {
+		return fmt.Errorf("reload interval must not be negative")
+	}
 	protocol := "http"
+	var certificate *server.Certificate
 	if options.TLSCert != "" && options.TLSPrivateKey != "" {
 		protocol += "s"
+		certificate, err = server.LoadCertificate(options.TLSCert, options.TLSPrivateKey)
+		if err != nil {
+			return err
+		}
 	}
 	var generatedKeys []string
-	var signPrivateKey ed25519.PrivateKey
-	var symmetricKey []byte
-	if options.EnableSignVerification {
-		signPrivateKey, err = cipher.LoadPrivateKey(options.SignPrivateKey)
+	var signKeys *server.KeyStore
+	var symmetricKey *cipher.Key
+	if options.EnableSignVerification && options.TrustedKeys != "" {
+		if options.SignPrivateKey != "" {
+			return fmt.Errorf("-x and -trusted-keys options can't be used together")
+		}
+		trustedKeys, err := loadTrustedKeys(deps.Logger, options.TrustedKeys)
+		if err != nil {
+			return err
+		}
+		signKeys = server.NewKeyStore(trustedKeys)
+	} else if options.EnableSignVerification {
+		signPrivateKey, err := cipher.LoadPrivateKey(options.SignPrivateKey)
 		if err != nil {
 			return err
 		}
@@ -100,14 +198,16 @@ func run(deps *ServerDeps) error {
 			}
 			generatedKeys = append(generatedKeys, pemKey)
 		}
+		signKeys = server.NewKeyStore(server.KeySet{"": signPrivateKey.Public().(ed25519.PublicKey)})
 	}
 	if options.EnableEncryption {
-		symmetricKey, err = cipher.LoadSymmetricKey(options.SymmetricKey)
+		key, err := cipher.LoadSymmetricKey(options.SymmetricKey)
 		if err != nil {
 			return err
 		}
+		symmetricKey = cipher.NewKey(key)
 		if options.SymmetricKey == "" {
-			symmetricKeyDisplay := cipher.Base64(symmetricKey)
+			symmetricKeyDisplay := cipher.Base64(key)
 			generatedKeys = append(generatedKeys,
 				fmt.Sprintf("ENCRYPTION KEY\n%s", symmetricKeyDisplay))
 		}
@@ -116,14 +216,12 @@ func run(deps *ServerDeps) error {
 	if err != nil {
 		return err
 	}
-	build, err := build.New(html, filesystem, "web/", version, options.SessionName, options.Editor, options.EnableEncryption, options.EnableSignVerification)
-	if err != nil {
-		return err
-	}
-	content := build.Bytes()
-	gzipped, err := server.GzipContent(content)
-	if err != nil {
-		return err
+	page := func(sessionName string) ([]byte, error) {
+		build, err := build.New(html, filesystem, "web/", version, sessionName, options.Editor, options.EnableEncryption, options.EnableSignVerification)
+		if err != nil {
+			return nil, err
+		}
+		return server.GzipContent(build.Bytes())
 	}
 	displayAddress := server.DisplayAddress(options.Address, anyIPv4, anyIPv6)
 	listener, err := server.NewListener(options.Address, options.Port)
@@ -132,25 +230,115 @@ func run(deps *ServerDeps) error {
 	}
 	displayPort := listener.Addr().(*net.TCPAddr).Port
 	displayAddress = server.FormatDisplayAddress(protocol, displayAddress, displayPort)
-	lockManager := pausectl.NewManager(5*time.Minute, 1*time.Minute)
-	pauseController := pause.New(lockManager, deps.Messages, deps.Logger)
-	sse.StartDispatcher(deps.Messages, deps.Clients, deps.ClientsMu, symmetricKey)
+	queue := sse.Queue{
+		Size:   options.QueueSize,
+		Policy: queuePolicy,
+		Stats:  deps.QueueStats,
+	}
+	sessions, err := session.NewRegistry(session.Config{
+		HistorySize:    options.HistorySize,
+		HistoryAge:     options.HistoryAge,
+		Queue:          queue,
+		SymmetricKey:   symmetricKey,
+		LockExpiration: options.PauseTTL,
+		LockCleanup:    options.PauseCleanup,
+		MaxSessions:    options.MaxSessions,
+		Page:           page,
+	}, options.SessionName)
+	if err != nil {
+		return err
+	}
+	heartbeat := sse.Heartbeat{
+		Interval:     options.Heartbeat,
+		WriteTimeout: options.WriteTimeout,
+	}
+	var accessLogExclude []string
+	if !options.AccessLogStream {
+		accessLogExclude = append(accessLogExclude, "/stream")
+	}
+	accessLog := server.AccessLog(accessLogger, accessLogExclude...)
 	middlewares := []func(http.Handler) http.Handler{server.WithHeaders}
 	clientSignMiddleware := append([]func(http.Handler) http.Handler{}, middlewares...)
 	if options.EnableSignVerification {
+		// Zero sizes the nonce cache from the skew window
+		nonceCacheSize := options.SignNonceCache
+		if nonceCacheSize == 0 {
+			nonceCacheSize = server.NonceCacheSize(defaultSignRate, options.SignMaxSkew)
+		}
 		clientSignMiddleware = append(
 			clientSignMiddleware,
-			server.VerifySignature(signPrivateKey.Public().(ed25519.PublicKey)),
+			server.VerifySignature(signKeys, server.SignatureConfig{
+				MaxSkew: options.SignMaxSkew,
+				Nonces:  server.NewNonceCache(nonceCacheSize),
+				Legacy:  options.SignLegacy,
+			}),
 		)
 	}
-	http.Handle("GET /", middleware(spa.Handle(gzipped), middlewares...))
-	http.Handle("POST /messages", middleware(message.Handle(deps.Messages, deps.Logger), clientSignMiddleware...))
-	http.Handle("POST /pauses", middleware(pauseController.Post(), clientSignMiddleware...))
-	http.Handle("GET /pauses/{id}", middleware(pauseController.Get(), clientSignMiddleware...))
-	http.Handle("GET /stream", middleware(sse.Handle(deps.Messages, deps.Logger, deps.Clients, deps.ClientsMu), middlewares...))
-	// These are meant to be issued from the user interface (no need to sign)
-	http.Handle("PATCH /pauses/{id}", middleware(pauseController.Patch(), middlewares...))
-	http.Handle("DELETE /pauses/{id}", middleware(pauseController.Delete(), middlewares...))
+	requestMetrics := server.Metrics("/stream")
+	clientSignMiddleware = append(clientSignMiddleware, server.LimitBody(int64(options.MaxBodySize)), requestMetrics, accessLog)
+	// The user interface pages and routes require the access token when set
+	pageMiddlewares := append([]func(http.Handler) http.Handler{}, middlewares...)
+	uiMiddlewares := append([]func(http.Handler) http.Handler{}, middlewares...)
+	var metricsMiddlewares []func(http.Handler) http.Handler
+	if options.UIToken != "" {
+		uiAuth := auth.New(auth.Config{
+			Token:      options.UIToken,
+			SessionTTL: options.UISessionTTL,
+			Secure:     certificate != nil,
+		})
+		pageMiddlewares = append(pageMiddlewares, uiAuth.RequirePage)
+		uiMiddlewares = append(uiMiddlewares, uiAuth.Require)
+		metricsMiddlewares = append(metricsMiddlewares, uiAuth.Require)
+		http.Handle("GET /login", middleware(uiAuth.Login(), requestMetrics, accessLog))
+		http.Handle("POST /login", middleware(uiAuth.Login(), server.LimitBody(int64(options.MaxBodySize)), requestMetrics, accessLog))
+		http.Handle("POST /logout", middleware(uiAuth.Logout(), requestMetrics, accessLog))
+	}
+	pageMiddlewares = append(pageMiddlewares, requestMetrics, accessLog)
+	uiMiddlewares = append(uiMiddlewares, requestMetrics, accessLog)
+	metricsMiddlewares = append(metricsMiddlewares, requestMetrics, accessLog)
+	registerMetrics(sessions, deps.QueueStats)
+	http.Handle("GET /metrics", middleware(metrics.Default.Handler(), metricsMiddlewares...))
+	http.Handle("GET /", middleware(spa.Handle(sessions.Default().Page), pageMiddlewares...))
+	http.Handle("GET /sessions/{name}", middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+	}), accessLog))
+	http.Handle("GET /sessions/{name}/{$}", middleware(sessions.Handle(func(s *session.Session) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			spa.Write(w, s.Page)
+		})
+	}), pageMiddlewares...))
+	// Unprefixed routes belong to the default session
+	for _, prefix := range []string{"", "/sessions/{name}"} {
+		// Only signed requests sending messages create sessions
+		http.Handle("POST "+prefix+"/messages", middleware(sessions.HandleCreate(func(s *session.Session) http.Handler {
+			return message.Handle(s.Messages, deps.Logger)
+		}), clientSignMiddleware...))
+		http.Handle("POST "+prefix+"/messages/batch", middleware(sessions.HandleCreate(func(s *session.Session) http.Handler {
+			return message.Batch(s.Messages, deps.Logger)
+		}), clientSignMiddleware...))
+		http.Handle("POST "+prefix+"/pauses", middleware(sessions.HandleCreate(func(s *session.Session) http.Handler {
+			return pause.New(s.Locks, s.Messages, deps.Logger).Post()
+		}), clientSignMiddleware...))
+		http.Handle("GET "+prefix+"/pauses/{id}", middleware(sessions.Handle(func(s *session.Session) http.Handler {
+			return pause.New(s.Locks, s.Messages, deps.Logger).Get()
+		}), clientSignMiddleware...))
+		http.Handle("GET "+prefix+"/stream", middleware(sessions.Handle(func(s *session.Session) http.Handler {
+			return sse.Handle(s.Messages, deps.Logger, s.Clients, s.ClientsMu, s.History, queue, heartbeat)
+		}), uiMiddlewares...))
+		// These are meant to be issued from the user interface (no need to sign)
+		http.Handle("DELETE "+prefix+"/messages", middleware(sessions.Handle(func(s *session.Session) http.Handler {
+			return message.Clear(s.Messages, deps.Logger)
+		}), uiMiddlewares...))
+		http.Handle("PATCH "+prefix+"/pauses/{id}", middleware(sessions.Handle(func(s *session.Session) http.Handler {
+			return pause.New(s.Locks, s.Messages, deps.Logger).Patch()
+		}), uiMiddlewares...))
+		http.Handle("DELETE "+prefix+"/pauses/{id}", middleware(sessions.Handle(func(s *session.Session) http.Handler {
+			return pause.New(s.Locks, s.Messages, deps.Logger).Delete()
+		}), uiMiddlewares...))
+		http.Handle("POST "+prefix+"/pauses/{id}/refresh", middleware(sessions.Handle(func(s *session.Session) http.Handler {
+			return pause.New(s.Locks, s.Messages, deps.Logger).Refresh()
+		}), uiMiddlewares...))
+	}
 	logo, err := filesystem.ReadFile("assets/logo")
 	if err != nil {
 		return err
@@ -164,10 +352,109 @@ func run(deps *ServerDeps) error {
 		DisplayAddress: displayAddress,
 		GeneratedKeys:  joinGeneratedKeys(generatedKeys),
 	})
-	if protocol == "https" {
-		return http.ServeTLS(listener, nil, options.TLSCert, options.TLSPrivateKey)
+	srv := &http.Server{}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	watcher := reload.New(deps.Logger)
+	watchFiles(watcher, deps.Logger, options, certificate, signKeys, symmetricKey)
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+	go watcher.Run(ctx, options.ReloadInterval, hangup)
+	if options.SessionIdleTTL > 0 {
+		go removeIdleSessions(ctx, sessions, deps.Logger, options.SessionIdleTTL)
 	}
-	return http.Serve(listener, nil)
+	serveErr := make(chan error, 1)
+	go func() {
+		if certificate != nil {
+			srv.TLSConfig = &tls.Config{GetCertificate: certificate.GetCertificate}
+			serveErr <- srv.ServeTLS(listener, "", "")
+			return
+		}
+		serveErr <- srv.Serve(listener)
+	}()
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+	stop()
+	return shutdown(srv, sessions, deps.Logger, options.ShutdownTimeout)
+}
+
+// shutdown gracefully stops the server within the timeout. Clients are
+// notified, outstanding pause locks are released and the pending messages
+// are delivered before closing the connections.
+func shutdown(srv *http.Server, sessions *session.Registry, logger cli.Logger, timeout time.Duration) error {
+	cli.Info(logger, "Shutting down", "timeout", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	// The sessions are shut down once the listeners are closed, so no stream
+	// connects after the shutdown event is broadcast
+	drained := make(chan error, 1)
+	srv.RegisterOnShutdown(func() {
+		drained <- sessions.Shutdown(ctx, func(s *session.Session, id string) {
+			cli.Warn(logger, "Released pause lock", "session", s.Name, "message_id", id)
+		})
+	})
+	err := srv.Shutdown(ctx)
+	if err := <-drained; err != nil {
+		cli.Warn(logger, "Sessions not drained", "error", err)
+	}
+	if err != nil {
+		srv.Close()
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	cli.Info(logger, "Shutdown complete")
+	return nil
+}
+
+// removeIdleSessions removes the sessions idle for ttl, checking every
+// minute or ttl, whichever is shorter, until ctx is done.
+func removeIdleSessions(ctx context.Context, sessions *session.Registry, logger cli.Logger, ttl time.Duration) {
+	ticker := time.NewTicker(min(ttl, time.Minute))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, name := range sessions.RemoveIdle(ttl) {
+				cli.Info(logger, "Removed idle session", "session", name)
+			}
+		}
+	}
+}
+
+// registerMetrics registers the metrics collected from the sessions and
+// the stream queues on each scrape.
+func registerMetrics(sessions *session.Registry, stats *sse.Stats) {
+	metrics.Default.GaugeFunc("xrdebug_sse_clients",
+		"Number of connected stream clients.", []string{"session"},
+		func(set func(float64, ...string)) {
+			sessions.Each(func(s *session.Session) {
+				s.ClientsMu.Lock()
+				defer s.ClientsMu.Unlock()
+				set(float64(len(s.Clients)), s.Name)
+			})
+		})
+	metrics.Default.GaugeFunc("xrdebug_messages_queue_depth",
+		"Number of messages waiting for the dispatcher.", []string{"session"},
+		func(set func(float64, ...string)) {
+			sessions.Each(func(s *session.Session) {
+				set(float64(len(s.Messages)), s.Name)
+			})
+		})
+	metrics.Default.CounterFunc("xrdebug_sse_events_dropped_total",
+		"Number of events discarded by full client queues.", nil,
+		func(set func(float64, ...string)) {
+			set(float64(stats.Dropped.Load()))
+		})
+	metrics.Default.CounterFunc("xrdebug_sse_clients_disconnected_total",
+		"Number of clients disconnected by full queues.", nil,
+		func(set func(float64, ...string)) {
+			set(float64(stats.Disconnected.Load()))
+		})
 }
 
 func joinGeneratedKeys(keys []string) string {
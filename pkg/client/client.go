@@ -0,0 +1,352 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+// Package client sends debug messages and pauses to an xrDebug server.
+//
+//	c := client.New("http://localhost:27420", client.WithPrivateKey(key))
+//	err := c.Dump(ctx, client.Message{Body: "Hello", Topic: "greet"})
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default settings used by New
+const (
+	DefaultURL          = "http://localhost:27420"
+	DefaultTimeout      = 10 * time.Second
+	DefaultPollInterval = time.Second
+	DefaultWait         = 30 * time.Second
+)
+
+// ErrStopped is returned by Pause when the pause is stopped from the user
+// interface, the caller should stop its execution.
+var ErrStopped = errors.New("xrdebug: execution stopped")
+
+// Message is a debug message. Empty fields are not sent.
+type Message struct {
+	// Body is the message content, which may contain HTML
+	Body string
+	// Topic categorizes the message
+	Topic string
+	// Emote is an emotion indicator
+	Emote string
+	// ID identifies the message, pauses get a random one when empty
+	ID string
+	// FilePath is the file where the message was sent, captured from the
+	// caller when empty (see WithCaller)
+	FilePath string
+	// FileLine is the line in FilePath
+	FileLine int
+}
+
+// Client sends messages to a session of an xrDebug server
+type Client struct {
+	baseURL      string
+	session      string
+	privateKey   ed25519.PrivateKey
+	keyID        string
+	httpClient   *http.Client
+	timeout      time.Duration
+	pollInterval time.Duration
+	wait         time.Duration
+	caller       bool
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithPrivateKey signs the requests with the ed25519 private key, which
+// is required when the server runs with sign verification.
+func WithPrivateKey(privateKey ed25519.PrivateKey) Option {
+	return func(c *Client) {
+		c.privateKey = privateKey
+	}
+}
+
+// WithKeyID sends the key id of the private key, which selects the public
+// key among the ones trusted by the server.
+func WithKeyID(id string) Option {
+	return func(c *Client) {
+		c.keyID = id
+	}
+}
+
+// WithSession sends the messages to the named session instead of the
+// default one.
+func WithSession(name string) Option {
+	return func(c *Client) {
+		c.session = name
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for the requests.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTimeout sets the timeout for each request, use 0 for no timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithPollInterval sets the interval between pause status checks.
+func WithPollInterval(interval time.Duration) Option {
+	return func(c *Client) {
+		c.pollInterval = interval
+	}
+}
+
+// WithWait sets how long each pause status check waits on the server for
+// the pause to be continued or stopped, use 0 to poll without waiting.
+func WithWait(wait time.Duration) Option {
+	return func(c *Client) {
+		c.wait = wait
+	}
+}
+
+// WithCaller sets whether the file and line of the caller are captured
+// for messages without FilePath, which is enabled by default.
+func WithCaller(enabled bool) Option {
+	return func(c *Client) {
+		c.caller = enabled
+	}
+}
+
+// New creates a Client for the server at baseURL (DefaultURL when empty).
+func New(baseURL string, options ...Option) *Client {
+	if baseURL == "" {
+		baseURL = DefaultURL
+	}
+	c := &Client{
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
+		httpClient:   http.DefaultClient,
+		timeout:      DefaultTimeout,
+		pollInterval: DefaultPollInterval,
+		wait:         DefaultWait,
+		caller:       true,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// Dump sends a debug message.
+func (c *Client) Dump(ctx context.Context, m Message) error {
+	values := c.values(m, 2)
+	_, err := c.do(ctx, http.MethodPost, "/messages", values, 0, http.StatusOK)
+	return err
+}
+
+// Pause sends a pause and blocks until it is continued from the user
+// interface. It returns ErrStopped when the pause is stopped, or the
+// context error when ctx is done first.
+func (c *Client) Pause(ctx context.Context, m Message) error {
+	values := c.values(m, 2)
+	if values.Get("id") == "" {
+		id, err := NewID()
+		if err != nil {
+			return err
+		}
+		values.Set("id", id)
+	}
+	id := values.Get("id")
+	if _, err := c.do(ctx, http.MethodPost, "/pauses", values, 0, http.StatusCreated); err != nil {
+		return err
+	}
+	var query url.Values
+	if c.wait > 0 {
+		query = url.Values{"wait": {c.wait.String()}}
+	}
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		data, err := c.do(ctx, http.MethodGet, "/pauses/"+url.PathEscape(id), query, c.wait, http.StatusOK, http.StatusNotFound)
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			return nil
+		}
+		var lock struct {
+			Stop bool `json:"stop"`
+		}
+		if err := json.Unmarshal(data, &lock); err != nil {
+			return fmt.Errorf("xrdebug: invalid pause response: %w", err)
+		}
+		if lock.Stop {
+			return ErrStopped
+		}
+	}
+}
+
+// values returns the form fields for the message, capturing the caller
+// skip frames above.
+func (c *Client) values(m Message, skip int) url.Values {
+	if m.FilePath == "" && c.caller {
+		if _, file, line, ok := runtime.Caller(skip); ok {
+			m.FilePath, m.FileLine = file, line
+		}
+	}
+	values := url.Values{}
+	fields := map[string]string{
+		"body":      m.Body,
+		"topic":     m.Topic,
+		"emote":     m.Emote,
+		"id":        m.ID,
+		"file_path": m.FilePath,
+	}
+	if m.FileLine > 0 {
+		fields["file_line"] = strconv.Itoa(m.FileLine)
+	}
+	for key, value := range fields {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	return values
+}
+
+// do sends a signed request and returns the response body, which is nil
+// for the not found status. Any status other than the expected ones fails.
+// The values are sent in the query for GET requests and the timeout is
+// extended by wait.
+func (c *Client) do(ctx context.Context, method, path string, values url.Values, wait time.Duration, expected ...int) ([]byte, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout+wait)
+		defer cancel()
+	}
+	target := c.baseURL
+	if c.session != "" {
+		target += "/sessions/" + url.PathEscape(c.session)
+	}
+	var body string
+	if method == http.MethodGet {
+		if len(values) > 0 {
+			path += "?" + values.Encode()
+		}
+	} else if values != nil {
+		body = values.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target+path, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if c.privateKey != nil {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		nonce, err := randomHex()
+		if err != nil {
+			return nil, err
+		}
+		message := canonicalRequest(method, req.URL.RequestURI(), timestamp, nonce, []byte(body))
+		signature := ed25519.Sign(c.privateKey, message)
+		req.Header.Set("X-Signature", base64.StdEncoding.EncodeToString(signature))
+		req.Header.Set("X-Signature-Version", "2")
+		req.Header.Set("X-Signature-Timestamp", timestamp)
+		req.Header.Set("X-Signature-Nonce", nonce)
+		if c.keyID != "" {
+			req.Header.Set("X-Key-Id", c.keyID)
+		}
+	}
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range expected {
+		if res.StatusCode != status {
+			continue
+		}
+		if status == http.StatusNotFound {
+			return nil, nil
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("xrdebug: %s %s: %s %s", method, path, res.Status, strings.TrimSpace(string(data)))
+}
+
+// NewID returns a random message id, as given to the pauses sent without
+// one, for callers needing to know the id before sending the pause.
+func NewID() (string, error) {
+	return randomHex()
+}
+
+// randomHex returns 16 random bytes encoded as hex, used for the pause ids
+// and the signature nonces.
+func randomHex() (string, error) {
+	data := make([]byte, 16)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
+
+// canonicalRequest returns the signed message of the version 2 signatures,
+// which binds the method, the request URI (path and query) and the body hash
+// to the signature timestamp and nonce, one per line after the `xrdebug-v2`
+// prefix. It must match the message verified by the server.
+func canonicalRequest(method, requestURI, timestamp, nonce string, body []byte) []byte {
+	hash := sha256.Sum256(body)
+	return []byte(strings.Join([]string{
+		"xrdebug-v2", method, requestURI, timestamp, nonce, hex.EncodeToString(hash[:]),
+	}, "\n"))
+}
+
+// ParsePrivateKey parses a PEM encoded (PKCS#8) ed25519 private key, such
+// as the one written by `xrdebug keygen`.
+func ParsePrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("xrdebug: failed to decode PEM block")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("xrdebug: failed to parse private key: %w", err)
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("xrdebug: key is not an ed25519 private key")
+	}
+	return privateKey, nil
+}
@@ -0,0 +1,238 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xrdebug/xrdebug/internal/cipher"
+	"github.com/xrdebug/xrdebug/internal/server"
+)
+
+// testServer records the signed requests and answers the pause checks
+// with the given statuses, in order.
+type testServer struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	forms    []url.Values
+	checks   []string
+}
+
+func (s *testServer) handler(publicKey ed25519.PublicKey) http.Handler {
+	mux := http.NewServeMux()
+	record := func(status int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			r.ParseForm()
+			s.mu.Lock()
+			s.requests = append(s.requests, r)
+			s.forms = append(s.forms, r.Form)
+			s.mu.Unlock()
+			w.WriteHeader(status)
+		}
+	}
+	mux.Handle("POST /sessions/{name}/messages", record(http.StatusOK))
+	mux.Handle("POST /messages", record(http.StatusOK))
+	mux.Handle("POST /pauses", record(http.StatusCreated))
+	mux.HandleFunc("GET /pauses/{id}", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		check := s.checks[0]
+		if len(s.checks) > 1 {
+			s.checks = s.checks[1:]
+		}
+		if check == "" {
+			http.Error(w, "Lock not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(check))
+	})
+	return server.VerifySignature(server.KeySet{"laptop": publicKey}, server.SignatureConfig{
+		MaxSkew: time.Minute,
+		Nonces:  server.NewNonceCache(100),
+	})(mux)
+}
+
+func newTestClient(t *testing.T, checks ...string) (*Client, *testServer) {
+	t.Helper()
+	privateKey, err := cipher.LoadPrivateKey("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := &testServer{checks: checks}
+	srv := httptest.NewServer(ts.handler(privateKey.Public().(ed25519.PublicKey)))
+	t.Cleanup(srv.Close)
+	return New(srv.URL, WithPrivateKey(privateKey), WithPollInterval(time.Millisecond)), ts
+}
+
+func TestDump(t *testing.T) {
+	c, ts := newTestClient(t)
+	err := c.Dump(context.Background(), Message{Body: "<b>hello</b>", Topic: "greet"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	form := ts.forms[0]
+	if form.Get("body") != "<b>hello</b>" || form.Get("topic") != "greet" {
+		t.Errorf("Expected message fields, got %v", form)
+	}
+	if !strings.HasSuffix(form.Get("file_path"), "client_test.go") || form.Get("file_line") == "" {
+		t.Errorf("Expected caller file, got %s:%s", form.Get("file_path"), form.Get("file_line"))
+	}
+	if _, found := form["emote"]; found {
+		t.Error("Expected empty fields to be omitted")
+	}
+}
+
+func TestDumpOptions(t *testing.T) {
+	c, ts := newTestClient(t)
+	WithSession("backend")(c)
+	WithCaller(false)(c)
+	if err := c.Dump(context.Background(), Message{Body: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := ts.requests[0].URL.Path; got != "/sessions/backend/messages" {
+		t.Errorf("Expected session path, got %s", got)
+	}
+	if _, found := ts.forms[0]["file_path"]; found {
+		t.Error("Expected no caller file")
+	}
+}
+
+func TestDumpRepeated(t *testing.T) {
+	c, ts := newTestClient(t)
+	for range 2 {
+		if err := c.Dump(context.Background(), Message{Body: "hi"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first, second := ts.requests[0].Header, ts.requests[1].Header
+	if first.Get("X-Signature-Timestamp") == "" {
+		t.Error("Expected signature timestamp")
+	}
+	if got := first.Get("X-Signature-Version"); got != "2" {
+		t.Errorf("Expected signature version 2, got %q", got)
+	}
+	if first.Get("X-Signature-Nonce") == second.Get("X-Signature-Nonce") {
+		t.Error("Expected a different nonce for each request")
+	}
+}
+
+func TestDumpKeyID(t *testing.T) {
+	c, ts := newTestClient(t)
+	WithKeyID("laptop")(c)
+	if err := c.Dump(context.Background(), Message{Body: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := ts.requests[0].Header.Get("X-Key-Id"); got != "laptop" {
+		t.Errorf("Expected key id header, got %q", got)
+	}
+}
+
+func TestDumpUnsigned(t *testing.T) {
+	c, _ := newTestClient(t)
+	c.privateKey = nil
+	err := c.Dump(context.Background(), Message{Body: "hi"})
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Expected unauthorized error, got %v", err)
+	}
+}
+
+func TestPause(t *testing.T) {
+	tests := []struct {
+		name     string
+		checks   []string
+		expected error
+	}{
+		{"continued", []string{`{"stop":false}`, ""}, nil},
+		{"stopped", []string{`{"stop":false}`, `{"stop":true}`}, ErrStopped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, ts := newTestClient(t, tt.checks...)
+			err := c.Pause(context.Background(), Message{Body: "wait"})
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+			if ts.forms[0].Get("id") == "" {
+				t.Error("Expected a random pause id")
+			}
+		})
+	}
+}
+
+func TestNewID(t *testing.T) {
+	id, err := NewID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(id) != 32 {
+		t.Errorf("Expected 32 hex characters, got %q", id)
+	}
+	if again, _ := NewID(); again == id {
+		t.Errorf("Expected different ids, got %s twice", id)
+	}
+}
+
+func TestPauseContext(t *testing.T) {
+	c, _ := newTestClient(t, `{"stop":false}`)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := c.Pause(ctx, Message{ID: "abc"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	privateKey, err := cipher.LoadPrivateKey("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemKey, err := cipher.PemKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParsePrivateKey([]byte(pemKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsed.Equal(privateKey) {
+		t.Error("Expected the same private key")
+	}
+	if _, err := ParsePrivateKey([]byte("invalid")); err == nil {
+		t.Error("Expected error for invalid PEM")
+	}
+}
+
+func TestCanonicalRequest(t *testing.T) {
+	tests := []struct {
+		method     string
+		requestURI string
+		body       string
+	}{
+		{http.MethodPost, "/messages", "body=test&topic=a"},
+		{http.MethodGet, "/sessions/team/pauses/1?wait=30s", ""},
+		{http.MethodDelete, "/pauses/a%2Fb", ""},
+	}
+	for _, tt := range tests {
+		got := canonicalRequest(tt.method, tt.requestURI, "1700000000", "0123456789abcdef", []byte(tt.body))
+		expected := server.CanonicalRequest(tt.method, tt.requestURI, "1700000000", "0123456789abcdef", []byte(tt.body))
+		if string(got) != string(expected) {
+			t.Errorf("Expected the server canonical request %q, got %q", expected, got)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package main
+
+import (
+	"crypto/ed25519"
+	"strings"
+
+	"github.com/xrdebug/xrdebug/internal/cipher"
+	"github.com/xrdebug/xrdebug/internal/cli"
+	"github.com/xrdebug/xrdebug/internal/reload"
+	"github.com/xrdebug/xrdebug/internal/server"
+)
+
+// loadTrustedKeys loads the trusted client public keys, logging their ids.
+func loadTrustedKeys(logger cli.Logger, path string) (server.KeySet, error) {
+	keys, err := cipher.LoadTrustedKeys(path)
+	if err != nil {
+		return nil, err
+	}
+	cli.Info(logger, "Trusted keys loaded", "path", path, "keys", strings.Join(cipher.KeyIDs(keys), ","))
+	return server.KeySet(keys), nil
+}
+
+// watchFiles registers the reload of the certificate and key files passed
+// in the options. Generated keys have no file and are kept as they are.
+func watchFiles(watcher *reload.Watcher, logger cli.Logger, options cli.Options, certificate *server.Certificate, signKeys *server.KeyStore, symmetricKey *cipher.Key) {
+	if certificate != nil {
+		watcher.Add("tls certificate", certificate.Reload, options.TLSCert, options.TLSPrivateKey)
+	}
+	if signKeys != nil && options.TrustedKeys != "" {
+		watcher.Add("trusted keys", func() error {
+			keys, err := loadTrustedKeys(logger, options.TrustedKeys)
+			if err != nil {
+				return err
+			}
+			signKeys.Store(keys)
+			return nil
+		}, options.TrustedKeys)
+	}
+	if signKeys != nil && options.SignPrivateKey != "" {
+		watcher.Add("sign key", func() error {
+			privateKey, err := cipher.LoadPrivateKey(options.SignPrivateKey)
+			if err != nil {
+				return err
+			}
+			signKeys.Store(server.KeySet{"": privateKey.Public().(ed25519.PublicKey)})
+			return nil
+		}, options.SignPrivateKey)
+	}
+	if symmetricKey != nil && options.SymmetricKey != "" {
+		watcher.Add("symmetric key", func() error {
+			key, err := cipher.LoadSymmetricKey(options.SymmetricKey)
+			if err != nil {
+				return err
+			}
+			symmetricKey.Store(key)
+			return nil
+		}, options.SymmetricKey)
+	}
+}
@@ -0,0 +1,107 @@
+/*
+ * This file is part of xrDebug.
+ *
+ * (c) Rodolfo Berrios <rodolfo@chevere.org>
+ *
+ * For the full copyright and license information, please view the LICENSE
+ * file that was distributed with this source code.
+ */
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/xrdebug/xrdebug/internal/cipher"
+	"github.com/xrdebug/xrdebug/internal/cli"
+	"github.com/xrdebug/xrdebug/pkg/client"
+)
+
+// SendOptions holds the options of the send command
+type SendOptions struct {
+	// URL is the server URL
+	URL string
+	// SessionName is the session receiving the message, empty for the default one
+	SessionName string
+	// SignPrivateKey is the path to the private key for signing requests
+	SignPrivateKey string
+	// KeyID is the id of the signing key trusted by the server
+	KeyID string
+	// Topic is the message topic
+	Topic string
+	// Emote is the message emote
+	Emote string
+	// FilePath is the file path shown with the message
+	FilePath string
+	// FileLine is the file line shown with the message
+	FileLine int
+	// ID is the message id
+	ID string
+	// Pause sends a pause and waits until it is continued
+	Pause bool
+	// PollInterval is the interval between pause status checks
+	PollInterval time.Duration
+}
+
+// send posts a message, or a pause when `-pause` is set, with the body read
+// from stdin. Pauses block until they are continued and fail with
+// client.ErrStopped when stopped.
+func send(args []string, stdin io.Reader, stdout io.Writer) error {
+	var options SendOptions
+	set := flag.NewFlagSet("send", flag.ExitOnError)
+	if err := cli.Parse(set, sendFlags, args, &options); err != nil {
+		return err
+	}
+	if options.Pause && options.PollInterval <= 0 {
+		return fmt.Errorf("poll interval must be greater than 0")
+	}
+	clientOptions := []client.Option{
+		client.WithSession(options.SessionName),
+		client.WithPollInterval(options.PollInterval),
+		client.WithCaller(false),
+	}
+	if options.SignPrivateKey != "" {
+		privateKey, err := cipher.LoadPrivateKey(options.SignPrivateKey)
+		if err != nil {
+			return err
+		}
+		clientOptions = append(clientOptions, client.WithPrivateKey(privateKey), client.WithKeyID(options.KeyID))
+	}
+	var body []byte
+	if stdin != nil {
+		var err error
+		if body, err = io.ReadAll(stdin); err != nil {
+			return fmt.Errorf("failed to read body: %w", err)
+		}
+	}
+	c := client.New(options.URL, clientOptions...)
+	message := client.Message{
+		Body:     string(body),
+		Topic:    options.Topic,
+		Emote:    options.Emote,
+		FilePath: options.FilePath,
+		FileLine: options.FileLine,
+		ID:       options.ID,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if !options.Pause {
+		return c.Dump(ctx, message)
+	}
+	if message.ID == "" {
+		id, err := client.NewID()
+		if err != nil {
+			return err
+		}
+		message.ID = id
+	}
+	fmt.Fprintf(stdout, "Pausing %s\n", message.ID)
+	return c.Pause(ctx, message)
+}
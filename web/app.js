@@ -30,6 +30,29 @@ let filter = {
             messageAction('PATCH', 'pauses', el);
         }
     },
+    // csrfToken returns the token of the login session, when there's one
+    csrfToken = function () {
+        let match = document.cookie.match(/(?:^|;\s*)xrdebug_csrf=([^;]*)/);
+        return match ? decodeURIComponent(match[1]) : "";
+    },
+    // clearMessages asks the server to clear the messages, every client
+    // clears them on the clear event
+    clearMessages = function () {
+        fetch("messages", {
+                method: "DELETE",
+                headers: {
+                    "X-CSRF-Token": csrfToken()
+                }
+            })
+            .then(function (response) {
+                if (response.status === 401) {
+                    window.location.reload();
+                }
+            })
+            .catch((error) => {
+                console.log("Error:", error);
+            });
+    },
     messageAction = function (method, endpoint, el) {
         let message = el.closest(".message");
         let data = [];
@@ -48,14 +71,19 @@ let filter = {
                 sjcl.bitArray.concat(nonce, encrypted)
             );
         }
-        fetch("/" + endpoint + "/" + message.dataset.id, {
+        fetch(endpoint + "/" + message.dataset.id, {
                 method: method,
                 headers: {
-                    "Content-Type": "application/json"
+                    "Content-Type": "application/json",
+                    "X-CSRF-Token": csrfToken()
                 },
                 body: data
             })
-            .then(function () {
+            .then(function (response) {
+                if (response.status === 401) {
+                    window.location.reload();
+                    return;
+                }
                 message
                     .querySelectorAll(".message-buttons--pause > button")
                     .forEach(function (el) {
@@ -105,10 +133,10 @@ let filter = {
             return;
         }
         if (action === "clear") {
-            windowActions.clear();
-        } else {
-            windowActions.pushStatus(action);
+            clearMessages();
+            return;
         }
+        windowActions.pushStatus(action);
         let actionDisplay = action.toUpperCase();
         action = 'window_' + action;
         pushMessage({
@@ -226,10 +254,11 @@ pushMessage = function (data, isStatus = false) {
     let bodyContextDisplay = el.querySelector(".body-context-display");
     bodyContextDisplay.textContent = data.file_display_short;
     if (data.file_display_short) {
-        bodyContextDisplay.innerHTML = "・"
-            + '<a href="'+ getEditorLink(EDITOR, data.file_path, data.file_line) +'">'
-            + data.file_display_short
-            + "</a>";
+        let link = document.createElement("a");
+        link.setAttribute("href", getEditorLink(EDITOR, data.file_path, data.file_line));
+        link.textContent = data.file_display_short;
+        bodyContextDisplay.textContent = "・";
+        bodyContextDisplay.appendChild(link);
         bodyContextDisplay.setAttribute("title", "Open " + data.file_display);
     }
     document
@@ -267,12 +296,6 @@ pushMessage = function (data, isStatus = false) {
                 .add("message--removing");
             setTimeout(function () {
                 el.remove();
-                if(data.action == "window_clear"
-                    && document.querySelector("main").childElementCount == 0)
-                {
-                    splash();
-                }
-
             }, 250)
         }, 5000);
 
@@ -423,32 +446,88 @@ setTimeout(function () {
         .classList
         .add("body--splash-in");
 }, 100);
-es = new EventSource("stream");
-es.addEventListener("message", function (event) {
-    if (currentStatus === "stop") {
-        return;
+decrypt = function (data) {
+    if (!IS_ENCRYPTION_ENABLED) {
+        return data;
     }
-    let data = event.data
-    if (IS_ENCRYPTION_ENABLED) {
-        let ivCiphertextTagB64 = data;
-        let ivCiphertextTag = sjcl
-            .codec
-            .base64
-            .toBits(ivCiphertextTagB64);
-        let iv = sjcl
-            .bitArray
-            .bitSlice(ivCiphertextTag, 0, GCM_NONCE_LENGTH);
-        let cipherTextTag = sjcl
-            .bitArray
-            .bitSlice(ivCiphertextTag, GCM_NONCE_LENGTH);
-        let decrypted = sjcl
-            .mode
-            .gcm
-            .decrypt(cipher, cipherTextTag, iv, null, GCM_TAG_LENGTH);
-        data = sjcl
-            .codec
-            .utf8String
-            .fromBits(decrypted);
+    let ivCiphertextTag = sjcl
+        .codec
+        .base64
+        .toBits(data);
+    let iv = sjcl
+        .bitArray
+        .bitSlice(ivCiphertextTag, 0, GCM_NONCE_LENGTH);
+    let cipherTextTag = sjcl
+        .bitArray
+        .bitSlice(ivCiphertextTag, GCM_NONCE_LENGTH);
+    let decrypted = sjcl
+        .mode
+        .gcm
+        .decrypt(cipher, cipherTextTag, iv, null, GCM_TAG_LENGTH);
+    return sjcl
+        .codec
+        .utf8String
+        .fromBits(decrypted);
+}
+disablePauseButtons = function (id) {
+    document
+        .querySelectorAll('.message--pause[data-id="' + CSS.escape(id) + '"] .message-buttons--pause > button')
+        .forEach(function (el) {
+            el.setAttribute("disabled", "disabled")
+        });
+}
+lastActivity = Date.now();
+["mousemove", "keydown", "scroll"].forEach(function (type) {
+    document.addEventListener(type, function () {
+        lastActivity = Date.now();
+    }, { passive: true });
+});
+// Refresh the expiration of the pending pauses while the page is in use
+setInterval(function () {
+    if (document.visibilityState !== "visible" || Date.now() - lastActivity > PAUSE_REFRESH_INTERVAL) {
+        return;
     }
-    pushMessage(JSON.parse(data))
+    document
+        .querySelectorAll(".message--pause .message-buttons--pause > button:not([disabled])")
+        .forEach(function (el) {
+            let id = el.closest(".message").dataset.id;
+            fetch("pauses/" + encodeURIComponent(id) + "/refresh", {
+                    method: "POST",
+                    headers: {
+                        "X-CSRF-Token": csrfToken()
+                    }
+                })
+                .then(function (response) {
+                    if (response.status === 401) {
+                        window.location.reload();
+                    }
+                    if (response.status === 404) {
+                        disablePauseButtons(id);
+                    }
+                });
+        });
+}, PAUSE_REFRESH_INTERVAL);
+es = new EventSource("stream");
+["message", "pause"].forEach(function (type) {
+    es.addEventListener(type, function (event) {
+        if (currentStatus === "stop") {
+            return;
+        }
+        pushMessage(JSON.parse(decrypt(event.data)))
+    });
+});
+["pause-stop", "pause-continue", "pause-expired"].forEach(function (type) {
+    es.addEventListener(type, function (event) {
+        disablePauseButtons(JSON.parse(decrypt(event.data)).id);
+    });
+});
+es.addEventListener("shutdown", function () {
+    pushMessage({
+        message: "<b>Server shutdown</b> " + document.title,
+        action: "shutdown",
+    }, true);
+});
+es.addEventListener("clear", function () {
+    windowActions.clear();
+    splash();
 });